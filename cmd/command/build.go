@@ -0,0 +1,57 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/build"
+	"github.com/urfave/cli/v2"
+)
+
+var Build = cli.Command{
+	Name:  "build",
+	Usage: "Build a .deb package from a YAML spec",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "spec", Aliases: []string{"s"}, Usage: "path to the package spec", Value: "linuxpack-build.yaml"},
+		&cli.PathFlag{Name: "out", Usage: "directory to write the package to", Value: "."},
+		&cli.TimestampFlag{Name: "mod-time", Usage: "modification time of the files in the package, for reproducible builds (RFC 3339)", Layout: time.RFC3339},
+	},
+	Action: func(c *cli.Context) error {
+		spec, err := build.LoadSpec(c.Path("spec"))
+		if err != nil {
+			return err
+		}
+
+		modTime := time.Now().UTC()
+		if t := c.Timestamp("mod-time"); t != nil {
+			modTime = *t
+		}
+
+		err = os.MkdirAll(c.Path("out"), 0755)
+		if err != nil {
+			return err
+		}
+
+		outPath := filepath.Join(c.Path("out"), spec.Filename())
+		f, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		err = spec.Build(f, modTime)
+		if err != nil {
+			return fmt.Errorf("error building %s: %w", outPath, err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("built %s\n", outPath)
+		return nil
+	},
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// ConfigFlag is the global flag for the path to the config file.
+var ConfigFlag = &cli.PathFlag{Name: "config", Usage: "path to the repository config file", Value: config.DefaultFile}
+
+// loadConfig loads the config file given by the --config flag. The
+// default config file is optional, but a file passed explicitly must exist.
+func loadConfig(c *cli.Context) (config.Config, error) {
+	return config.Load(c.Path("config"), !c.IsSet("config"))
+}
+
+// The option functions below return the value of a flag if it was set,
+// falling back to the value from the config file, and then to the flag's default.
+
+func stringOption(c *cli.Context, name string, value string) string {
+	if c.IsSet(name) || value == "" {
+		return c.String(name)
+	}
+	return value
+}
+
+func pathOption(c *cli.Context, name string, value string) string {
+	if c.IsSet(name) || value == "" {
+		return c.Path(name)
+	}
+	return value
+}
+
+func sliceOption(c *cli.Context, name string, value []string) []string {
+	if c.IsSet(name) || len(value) == 0 {
+		return c.StringSlice(name)
+	}
+	return value
+}
+
+func boolOption(c *cli.Context, name string, value *bool) bool {
+	if c.IsSet(name) || value == nil {
+		return c.Bool(name)
+	}
+	return *value
+}
+
+func intOption(c *cli.Context, name string, value int) int {
+	if c.IsSet(name) || value == 0 {
+		return c.Int(name)
+	}
+	return value
+}
+
+func durationOption(c *cli.Context, name string, value time.Duration) time.Duration {
+	if c.IsSet(name) || value == 0 {
+		return c.Duration(name)
+	}
+	return value
+}
+
+// printInvalidation prints the command to invalidate the given paths in
+// the CloudFront distribution, if one is configured. The indexes are
+// overwritten on every run, so they must be invalidated for clients to
+// see the changes.
+func printInvalidation(cfg config.Config, paths ...string) {
+	id := cfg.CDN.CloudFrontDistributionID
+	if id == "" {
+		return
+	}
+
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = fmt.Sprintf("%q", p)
+	}
+
+	fmt.Printf("after uploading, invalidate the cached indexes with:\n")
+	fmt.Printf("aws cloudfront create-invalidation --distribution-id %s --paths %s\n", id, strings.Join(quoted, " "))
+}
@@ -0,0 +1,221 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/packager"
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/urfave/cli/v2"
+)
+
+var Diff = cli.Command{
+	Name:  "diff",
+	Usage: "Compare the APT packages of two repositories or channels",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{Name: "from", Usage: "the repository to compare from, as s3://<bucket>, gs://<bucket>, azblob://<account>/<container> or a local directory (defaults to the configured storage)"},
+		&cli.StringFlag{Name: "to", Usage: "the repository to compare to, as s3://<bucket> or a local directory (defaults to the configured output directory)"},
+		&cli.StringFlag{Name: "channel", Usage: "the channel to compare (required unless the config file has a single channel)"},
+		&cli.StringFlag{Name: "from-channel", Usage: "the channel to compare from (defaults to --channel)"},
+		&cli.StringFlag{Name: "to-channel", Usage: "the channel to compare to (defaults to --channel)"},
+		&cli.StringFlag{Name: "from-snapshot", Usage: "compare from a snapshot of the --from channel, rather than the published channel"},
+		&cli.StringFlag{Name: "to-snapshot", Usage: "compare to a snapshot of the --to channel, rather than the published channel"},
+		&cli.StringFlag{Name: "region", Usage: "the AWS region of S3 buckets"},
+		&cli.BoolFlag{Name: "json", Usage: "print the differences as JSON"},
+	}, s3Flags...),
+	Action: func(c *cli.Context) error {
+		ctx := c.Context
+
+		cfg, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+
+		from := c.String("from")
+		if from == "" {
+			from = storageLocation(cfg)
+		}
+		to := stringOption(c, "to", cfg.Out)
+		if from == "" || to == "" {
+			return errors.New("the repositories to compare must be provided with --from and --to")
+		}
+
+		channel := c.String("channel")
+		if channel == "" && len(cfg.Channels) == 1 {
+			channel = cfg.Channels[0].Name
+		}
+		fromChannel := stringOption(c, "from-channel", channel)
+		toChannel := stringOption(c, "to-channel", channel)
+		if fromChannel == "" || toChannel == "" {
+			return errors.New("a channel must be provided with --channel, or --from-channel and --to-channel")
+		}
+
+		fromStore, err := openLocation(c, cfg, from)
+		if err != nil {
+			return err
+		}
+		toStore, err := openLocation(c, cfg, to)
+		if err != nil {
+			return err
+		}
+
+		if name := c.String("from-snapshot"); name != "" {
+			fromStore, err = openSnapshot(ctx, fromStore, fromChannel, name)
+			if err != nil {
+				return err
+			}
+		}
+		if name := c.String("to-snapshot"); name != "" {
+			toStore, err = openSnapshot(ctx, toStore, toChannel, name)
+			if err != nil {
+				return err
+			}
+		}
+
+		a := diffSource{Store: fromStore, Channel: fromChannel}
+		b := diffSource{Store: toStore, Channel: toChannel}
+
+		diffs, err := diffRepositories(ctx, a, b, cfg.Components, cfg.Architectures)
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(diffs)
+		}
+
+		fmt.Printf("comparing %s to %s\n", fromStore.URL(path.Join("dists", fromChannel)), toStore.URL(path.Join("dists", toChannel)))
+
+		if len(diffs) == 0 {
+			fmt.Printf("no differences\n")
+			return nil
+		}
+
+		symbols := map[string]string{"added": "+", "removed": "-", "changed": "~"}
+
+		for _, d := range diffs {
+			fmt.Printf("\n%s/binary-%s:\n", d.Component, d.Architecture)
+			for _, change := range d.Changes {
+				fmt.Printf("  %s %s %s\n", symbols[change.Action], change.Package, change.Version)
+				for _, f := range change.Fields {
+					fmt.Printf("      %s: %s -> %s\n", f.Field, oneLine(f.From), oneLine(f.To))
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+// diffSource is a channel of a repository to compare.
+type diffSource struct {
+	Store   storage.Storage
+	Channel string
+}
+
+// openSnapshot returns a view of storage in which the files of a snapshot
+// are the indexes of its channel, so it can be compared like a channel.
+func openSnapshot(ctx context.Context, store storage.Storage, channel string, name string) (storage.Storage, error) {
+	index, err := packager.ReadSnapshots(ctx, store, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, ok := index.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("snapshot %q of channel %s does not exist in %s", name, channel, store.URL(path.Join("snapshots", channel)))
+	}
+
+	return snapshot.Open(store), nil
+}
+
+// indexDiff is the differences between the Packages indexes
+// of a component and architecture.
+type indexDiff struct {
+	Component    string              `json:"component"`
+	Architecture string              `json:"architecture"`
+	Changes      []packageset.Change `json:"changes"`
+}
+
+// diffRepositories compares the Packages indexes of two channels. The
+// components and architectures are read from the channels' Release files,
+// falling back to the given defaults if neither channel has been published.
+func diffRepositories(ctx context.Context, a diffSource, b diffSource, components []string, architectures []string) ([]indexDiff, error) {
+	var foundRelease bool
+	var releaseComponents, releaseArchitectures []string
+
+	for _, src := range []diffSource{a, b} {
+		release, err := packager.ReadExistingRelease(ctx, src.Store, src.Channel)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", src.Store.URL(path.Join("dists", src.Channel, "Release")), err)
+		}
+
+		foundRelease = true
+		releaseComponents = appendMissing(releaseComponents, strings.Fields(release.Components)...)
+		releaseArchitectures = appendMissing(releaseArchitectures, release.Architectures...)
+	}
+
+	if foundRelease {
+		components, architectures = releaseComponents, releaseArchitectures
+	}
+	if len(components) == 0 {
+		components = []string{"main"}
+	}
+	if len(architectures) == 0 {
+		architectures = packager.DefaultArchitectures
+	}
+
+	diffs := []indexDiff{}
+
+	for _, component := range components {
+		for _, arch := range architectures {
+			var sets [2]packageset.Set
+
+			for i, src := range []diffSource{a, b} {
+				set, err := packager.ReadPackages(ctx, src.Store, src.Channel, component, arch)
+				if err != nil && err != storage.ErrNotFound {
+					return nil, fmt.Errorf("error reading packages from %s: %w", src.Store.URL(path.Join("dists", src.Channel, component, "binary-"+arch)), err)
+				}
+				sets[i] = set
+			}
+
+			changes := packageset.Diff(sets[0], sets[1])
+			if len(changes) > 0 {
+				diffs = append(diffs, indexDiff{Component: component, Architecture: arch, Changes: changes})
+			}
+		}
+	}
+
+	return diffs, nil
+}
+
+// appendMissing appends the values which are not already in s.
+func appendMissing(s []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(s, v) {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+// oneLine shortens a multi-line field value, such as a long description, for display.
+func oneLine(s string) string {
+	line, _, found := strings.Cut(s, "\n")
+	if found {
+		return line + " ..."
+	}
+	return line
+}
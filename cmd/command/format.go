@@ -0,0 +1,67 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// packageFormat is the type of a package file.
+type packageFormat string
+
+const (
+	formatDeb    packageFormat = "deb"
+	formatRPM    packageFormat = "rpm"
+	formatAPK    packageFormat = "apk"
+	formatPacman packageFormat = "pacman"
+)
+
+// detectFormat detects the type of a package file from its magic bytes,
+// or its file name for pacman packages.
+func detectFormat(fileName string) (packageFormat, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 8)
+	_, err = io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	switch {
+	// pacman packages are compressed tarballs, which may be gzipped
+	// like an .apk, so they are identified by their file name
+	case strings.Contains(filepath.Base(fileName), ".pkg.tar"):
+		return formatPacman, nil
+	case bytes.HasPrefix(magic, []byte("!<arch>\n")):
+		return formatDeb, nil
+	case bytes.HasPrefix(magic, []byte{0xed, 0xab, 0xee, 0xdb}):
+		return formatRPM, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		// .apk packages are concatenated gzip streams
+		return formatAPK, nil
+	}
+
+	return "", fmt.Errorf("%s is not a supported package type", fileName)
+}
+
+// groupFiles groups package files by their format.
+func groupFiles(fileNames []string) (map[packageFormat][]string, error) {
+	files := map[packageFormat][]string{}
+
+	for _, fileName := range fileNames {
+		format, err := detectFormat(fileName)
+		if err != nil {
+			return nil, err
+		}
+		files[format] = append(files[format], fileName)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/config"
+	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/common-fate/linuxpack/pkg/deb"
+	"github.com/common-fate/linuxpack/pkg/lint"
+	"github.com/urfave/cli/v2"
+)
+
+// lintRuleFlag overrides the severity of lint rules.
+var lintRuleFlag = &cli.StringSliceFlag{Name: "lint-rule", Usage: "set the severity of a lint rule, as <rule>=<error|warning|off>"}
+
+var Lint = cli.Command{
+	Name:      "lint",
+	Usage:     "Check .deb packages against Debian policy before publishing them",
+	ArgsUsage: "<file.deb>...",
+	Flags: []cli.Flag{
+		lintRuleFlag,
+		&cli.BoolFlag{Name: "rules", Usage: "list the lint rules and their severities"},
+	},
+	Action: func(c *cli.Context) error {
+		cfg, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+
+		lintConfig, err := lintOptions(c, cfg)
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("rules") {
+			for _, r := range lint.Rules {
+				fmt.Printf("%-22s %-8s %s\n", r.Name, lintConfig.Severity(r), r.Description)
+			}
+			return nil
+		}
+
+		if c.NArg() == 0 {
+			return fmt.Errorf("at least one .deb package must be provided")
+		}
+
+		failed := 0
+		for _, fileName := range c.Args().Slice() {
+			pkg, err := readLintPackage(fileName)
+			if err != nil {
+				return err
+			}
+
+			findings := lint.Check(pkg, lintConfig)
+			if len(findings) == 0 {
+				fmt.Printf("%s: ok\n", fileName)
+			}
+			for _, f := range findings {
+				fmt.Printf("%s: %s\n", fileName, f)
+			}
+			if len(lint.Errors(findings)) > 0 {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d packages failed lint checks", failed, c.NArg())
+		}
+		return nil
+	},
+}
+
+// lintOptions returns the severity of the lint rules from the config
+// file, overridden by the --lint-rule flags.
+func lintOptions(c *cli.Context, cfg config.Config) (lint.Config, error) {
+	values := map[string]string{}
+	for name, sev := range cfg.Lint {
+		values[name] = sev
+	}
+
+	for _, rule := range c.StringSlice("lint-rule") {
+		name, sev, found := strings.Cut(rule, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid --lint-rule %q: expected <rule>=<severity>", rule)
+		}
+		values[name] = sev
+	}
+
+	return lint.ParseConfig(values)
+}
+
+// readLintPackage reads the metadata of a .deb package to lint.
+func readLintPackage(fileName string) (lint.Package, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return lint.Package{}, err
+	}
+	defer f.Close()
+
+	d, err := deb.Read(f)
+	if err != nil {
+		return lint.Package{}, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	ctrl, err := control.Parse(bytes.NewReader(d.Control))
+	if err != nil {
+		return lint.Package{}, fmt.Errorf("error reading the control file of %s: %w", fileName, err)
+	}
+
+	return lint.Package{FileName: filepath.Base(fileName), Control: ctrl, DataSize: d.DataSize}, nil
+}
@@ -1,41 +1,390 @@
 package command
 
 import (
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/common-fate/linuxpack/pkg/apk"
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/config"
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/override"
 	"github.com/common-fate/linuxpack/pkg/packager"
+	"github.com/common-fate/linuxpack/pkg/pacman"
+	"github.com/common-fate/linuxpack/pkg/plan"
+	"github.com/common-fate/linuxpack/pkg/rpm"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/common-fate/linuxpack/pkg/storage"
 	"github.com/urfave/cli/v2"
 )
 
 var Package = cli.Command{
 	Name: "package",
-	Flags: []cli.Flag{
-		&cli.StringSliceFlag{Name: "file", Aliases: []string{"f"}, FilePath: "files to package", Required: true},
-		&cli.StringFlag{Name: "licence", FilePath: "the licence to apply to the packages"},
-		&cli.StringFlag{Name: "vendor", FilePath: "the vendor to apply to the packages"},
-		&cli.StringFlag{Name: "bucket", FilePath: "the S3 bucket to store releases in"},
-		&cli.StringFlag{Name: "channel", FilePath: "the release channel to use", Required: true},
-		&cli.PathFlag{Name: "out", FilePath: "output directory", Required: true},
-	},
+	Flags: append([]cli.Flag{
+		&cli.StringSliceFlag{Name: "file", Aliases: []string{"f"}, Usage: "files to package", Required: true},
+		&cli.StringFlag{Name: "licence", Usage: "the licence to apply to the packages"},
+		&cli.StringFlag{Name: "vendor", Usage: "the vendor to apply to the packages"},
+		&cli.StringFlag{Name: "description", Usage: "the description of the repository"},
+		&cli.StringFlag{Name: "bucket", Usage: "the S3 or GCS bucket, or Azure container, to store releases in"},
+		&cli.StringFlag{Name: "region", Usage: "the AWS region of the S3 bucket"},
+		&cli.PathFlag{Name: "repo-dir", Usage: "a local directory holding the repository, which is read and updated in place instead of using S3"},
+		&cli.StringFlag{Name: "channel", Usage: "the release channel to use (required unless the config file has a single channel)"},
+		&cli.StringFlag{Name: "component", Usage: "the APT component to add the packages to (defaults to the first component)"},
+		&cli.StringSliceFlag{Name: "components", Usage: "the components of the APT repository", Value: cli.NewStringSlice("main")},
+		&cli.StringSliceFlag{Name: "architecture", Usage: "the architectures of the APT repository", Value: cli.NewStringSlice(packager.DefaultArchitectures...)},
+		&cli.IntFlag{Name: "keep-versions", Usage: "the number of versions of each package to keep in the APT indexes (0 keeps every version)"},
+		&cli.PathFlag{Name: "out", Usage: "output directory (required unless set in the config file)"},
+		&cli.BoolFlag{Name: "incremental", Usage: "only rewrite the files in the output directory which have changed, rather than replacing it"},
+		&cli.StringSliceFlag{Name: "compression", Usage: "compression formats to write indexes in (none, gz, xz, zst, bz2)", Value: cli.NewStringSlice("none", "gz")},
+		&cli.BoolFlag{Name: "contents", Usage: "generate Contents-<arch> indexes for apt-file", Value: true},
+		&cli.BoolFlag{Name: "translations", Usage: "move long descriptions into an i18n/Translation-en index"},
+		&cli.StringFlag{Name: "default-section", Usage: "the Section of .deb packages which don't set one, such as utils"},
+		&cli.StringFlag{Name: "default-priority", Usage: "the Priority of .deb packages which don't set one, such as optional"},
+		&cli.PathFlag{Name: "override-file", Usage: "path to a YAML file overriding the metadata of packages by name"},
+		lintRuleFlag,
+		&cli.IntFlag{Name: "concurrency", Usage: "the number of .deb packages to read and copy at once (defaults to the number of CPUs)"},
+		&cli.StringFlag{Name: "origin", Usage: "the Origin of the Release file (defaults to \"<vendor> APT Repository\")"},
+		&cli.StringFlag{Name: "label", Usage: "the Label of the Release file (defaults to the vendor)"},
+		&cli.StringFlag{Name: "release-version", Usage: "the Version of the Release file", Value: "1.0"},
+		&cli.DurationFlag{Name: "valid-for", Usage: "how long the Release file is valid for, used to set Valid-Until (e.g. 168h)"},
+		&cli.BoolFlag{Name: "not-automatic", Usage: "mark the channel as NotAutomatic so packages are only installed when explicitly requested"},
+		&cli.BoolFlag{Name: "but-automatic-upgrades", Usage: "allow automatic upgrades of packages installed from a NotAutomatic channel"},
+		&cli.StringSliceFlag{Name: "signed-by", Usage: "fingerprints of the keys the repository is signed by"},
+		&cli.StringFlag{Name: "sign-key", Usage: "the ID of the GPG key to sign the repository metadata with"},
+		&cli.PathFlag{Name: "apk-key", Usage: "path to the RSA private key to sign Alpine APKINDEX files with"},
+		&cli.StringFlag{Name: "apk-key-name", Usage: "the file name of the public key in /etc/apk/keys (defaults to the private key file name with a .pub suffix)"},
+		&cli.StringFlag{Name: "pacman-repo", Usage: "the name of the pacman repository, as used in pacman.conf (defaults to the channel)"},
+		&cli.BoolFlag{Name: "dry-run", Usage: "print the changes which would be made to the repository without writing the output directory"},
+		&cli.PathFlag{Name: "plan-json", Usage: "write the dry run plan as JSON to a file"},
+		&cli.StringSliceFlag{Name: "hash", Usage: "hash algorithms to checksum packages and indexes with (md5, sha1, sha256, sha512), including sha256 or sha512", Value: cli.NewStringSlice("md5", "sha1", "sha256", "sha512")},
+	}, s3Flags...),
 	Action: func(c *cli.Context) error {
 		ctx := c.Context
 
-		cfg, err := config.LoadDefaultConfig(ctx)
+		cfg, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+
+		channelName := c.String("channel")
+		if channelName == "" && len(cfg.Channels) == 1 {
+			channelName = cfg.Channels[0].Name
+		}
+		if channelName == "" {
+			return errors.New("a channel must be provided with --channel or in the config file")
+		}
+
+		channel, err := cfg.Channel(channelName)
+		if err != nil {
+			return err
+		}
+
+		dryRun := c.Bool("dry-run")
+		if c.IsSet("plan-json") && !dryRun {
+			return errors.New("--plan-json requires --dry-run")
+		}
+
+		repoDir := c.Path("repo-dir")
+		if repoDir == "" && cfg.Storage.Type == "local" && !c.IsSet("bucket") {
+			repoDir = cfg.Storage.Dir
+		}
+		if repoDir != "" && c.IsSet("bucket") {
+			return errors.New("only one of --bucket and --repo-dir can be provided")
+		}
+		if repoDir != "" && c.IsSet("out") {
+			return errors.New("--out can't be used with --repo-dir, as the repository is updated in place")
+		}
+
+		out := pathOption(c, "out", cfg.Out)
+		if repoDir != "" {
+			out = repoDir
+		}
+		if out == "" && !dryRun {
+			return errors.New("an output directory must be provided with --out or in the config file")
+		}
+
+		// a dry run writes the repository metadata to a temporary directory,
+		// which is compared with the published repository to create the plan
+		var pl *plan.Plan
+		if dryRun {
+			pl = plan.New()
+
+			out, err = os.MkdirTemp("", "linuxpack-plan-*")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(out)
+		}
+
+		// the repository is written to a staging directory, which is only
+		// moved into the output directory once every packager has succeeded
+		var stage *output.Staging
+		mode := output.Replace
+		if c.Bool("incremental") {
+			mode = output.Sync
+		}
+
+		// a local repository is updated in place, so the plan is used
+		// to delete the files which are no longer referenced
+		inPlace := repoDir != "" && !dryRun
+		if inPlace {
+			pl = plan.New()
+			mode = output.Merge
+		}
+
+		if !dryRun {
+			// fail before packaging if the output directory can't be replaced
+			if mode != output.Merge {
+				err = output.Check(out)
+				if err != nil {
+					return err
+				}
+			}
+
+			stage, err = output.NewStaging(out)
+			if err != nil {
+				return err
+			}
+			defer stage.Cleanup()
+			out = stage.Dir
+		}
+
+		formats, err := compression.ParseList(sliceOption(c, "compression", cfg.Compression))
+		if err != nil {
+			return err
+		}
+
+		lintConfig, err := lintOptions(c, cfg)
+		if err != nil {
+			return err
+		}
+
+		defaults := config.Defaults{
+			Section:  stringOption(c, "default-section", cfg.Defaults.Section),
+			Priority: stringOption(c, "default-priority", cfg.Defaults.Priority),
+		}
+		err = defaults.Validate()
+		if err != nil {
+			return err
+		}
+
+		var overrides override.File
+		if overrideFile := pathOption(c, "override-file", cfg.Overrides); overrideFile != "" {
+			overrides, err = override.Load(overrideFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		hashes, err := checksum.ParseList(sliceOption(c, "hash", cfg.Hashes))
+		if err != nil {
+			return err
+		}
+
+		files, err := groupFiles(c.StringSlice("file"))
+		if err != nil {
+			return err
+		}
+
+		var store storage.Storage
+		if repoDir != "" {
+			store = storage.Local{Dir: repoDir}
+		} else {
+			store, err = openStorage(c, cfg)
+			if err != nil {
+				return err
+			}
+		}
+
+		var signer signing.Signer
+		if keyID := stringOption(c, "sign-key", cfg.Signing.GPGKey); keyID != "" {
+			signer = signing.GPG{KeyID: keyID}
+		}
+
+		if len(files[formatDeb]) > 0 {
+			p := packager.Packager{
+				OutputFolder:    out,
+				Licence:         stringOption(c, "licence", cfg.Licence),
+				Vendor:          stringOption(c, "vendor", cfg.Vendor),
+				Channel:         channel.Name,
+				Files:           files[formatDeb],
+				Storage:         store,
+				Description:     stringOption(c, "description", firstNonEmpty(channel.Description, cfg.Description)),
+				Components:      sliceOption(c, "components", cfg.Components),
+				Component:       c.String("component"),
+				Architectures:   sliceOption(c, "architecture", cfg.Architectures),
+				KeepVersions:    intOption(c, "keep-versions", cfg.Retention.KeepVersions),
+				Compression:     formats,
+				Hashes:          hashes,
+				Contents:        boolOption(c, "contents", cfg.Contents),
+				Translations:    boolOption(c, "translations", cfg.Translations),
+				Overrides:       overrides,
+				Lint:            lintConfig,
+				DefaultSection:  defaults.Section,
+				DefaultPriority: defaults.Priority,
+				Concurrency:     c.Int("concurrency"),
+				Release: packager.ReleaseConfig{
+					Origin:               stringOption(c, "origin", cfg.Origin),
+					Label:                stringOption(c, "label", cfg.Label),
+					Version:              stringOption(c, "release-version", cfg.Version),
+					ValidFor:             durationOption(c, "valid-for", channel.ValidFor),
+					NotAutomatic:         boolOption(c, "not-automatic", &channel.NotAutomatic),
+					ButAutomaticUpgrades: boolOption(c, "but-automatic-upgrades", &channel.ButAutomaticUpgrades),
+					SignedBy:             sliceOption(c, "signed-by", cfg.Signing.SignedBy),
+				},
+				Signer: signer,
+				DryRun: dryRun,
+				Plan:   pl,
+			}
+
+			err = p.Package(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(files[formatRPM]) > 0 {
+			p := rpm.Packager{
+				Storage:      store,
+				OutputFolder: out,
+				Channel:      channel.Name,
+				Files:        files[formatRPM],
+				Signer:       signer,
+				DryRun:       dryRun,
+				Plan:         pl,
+			}
+
+			err = p.Package(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(files[formatAPK]) > 0 {
+			p := apk.Packager{
+				Storage:      store,
+				OutputFolder: out,
+				Channel:      channel.Name,
+				Description:  stringOption(c, "description", firstNonEmpty(channel.Description, cfg.Description)),
+				Files:        files[formatAPK],
+				DryRun:       dryRun,
+				Plan:         pl,
+			}
+
+			if keyPath := pathOption(c, "apk-key", cfg.Signing.APKKey); keyPath != "" {
+				p.Signer, err = apk.LoadSigner(keyPath, stringOption(c, "apk-key-name", cfg.Signing.APKKeyName))
+				if err != nil {
+					return err
+				}
+			}
+
+			err = p.Package(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(files[formatPacman]) > 0 {
+			p := pacman.Packager{
+				Storage:      store,
+				OutputFolder: out,
+				Channel:      channel.Name,
+				RepoName:     c.String("pacman-repo"),
+				Files:        files[formatPacman],
+				Signer:       signer,
+				DryRun:       dryRun,
+				Plan:         pl,
+			}
+
+			err = p.Package(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			return writePlan(c, pl, store, out)
+		}
+
+		err = stage.Commit(mode)
 		if err != nil {
 			return err
 		}
 
-		p := packager.Packager{
-			OutputFolder: c.Path("out"),
-			Licence:      c.String("licence"),
-			Vendor:       c.String("vendor"),
-			Channel:      c.String("channel"),
-			Files:        c.StringSlice("file"),
-			S3Client:     s3.NewFromConfig(cfg),
-			Bucket:       c.String("bucket"),
-			Description:  c.String("description"),
+		if inPlace {
+			err = deleteUnreferenced(repoDir, pl.Deletes)
+			if err != nil {
+				return err
+			}
 		}
 
-		return p.Package(ctx)
+		printInvalidation(cfg,
+			path.Join("/dists", channel.Name, "*"),
+			path.Join("/rpm", channel.Name, "*"),
+			path.Join("/alpine", channel.Name, "*"),
+			path.Join("/archlinux", channel.Name, "*"),
+		)
+
+		return nil
 	},
 }
+
+// firstNonEmpty returns the first non-empty value.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// deleteUnreferenced deletes the files of a local repository
+// which are no longer referenced by its indexes.
+func deleteUnreferenced(repoDir string, keys []string) error {
+	for _, key := range keys {
+		err := os.Remove(filepath.Join(repoDir, filepath.FromSlash(key)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s\n", key)
+	}
+	return nil
+}
+
+// writePlan compares the metadata written in a dry run with the
+// published repository, and prints the resulting plan.
+func writePlan(c *cli.Context, pl *plan.Plan, store storage.Storage, dir string) error {
+	err := pl.DiffIndexes(c.Context, store, dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nplan (dry run, nothing has been written):\n\n")
+	err = pl.Write(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if planPath := c.Path("plan-json"); planPath != "" {
+		data, err := json.MarshalIndent(pl, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(planPath, append(data, '\n'), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/common-fate/linuxpack/pkg/server"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/urfave/cli/v2"
+)
+
+var Serve = cli.Command{
+	Name:  "serve",
+	Usage: "Serve a generated repository over HTTP for testing",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "dir", Usage: "the directory to serve (defaults to the configured output directory)"},
+		&cli.StringFlag{Name: "addr", Usage: "the address to listen on", Value: "localhost:8080"},
+		&cli.PathFlag{Name: "key", Usage: "path to the public key to serve at /key.gpg"},
+		&cli.StringFlag{Name: "sign-key", Usage: "the ID of a GPG key to export and serve at /key.gpg"},
+	},
+	Action: func(c *cli.Context) error {
+		cfg, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+
+		dir := pathOption(c, "dir", cfg.Out)
+		if dir == "" {
+			return errors.New("a directory must be provided with --dir or in the config file")
+		}
+
+		h := server.Handler{Dir: dir}
+
+		switch {
+		case c.IsSet("key") && c.IsSet("sign-key"):
+			return errors.New("only one of --key and --sign-key can be provided")
+		case c.IsSet("key"):
+			h.PublicKey, err = os.ReadFile(c.Path("key"))
+		case c.IsSet("sign-key"):
+			h.PublicKey, err = signing.GPG{KeyID: c.String("sign-key")}.ExportPublicKey(c.Context)
+		}
+		if err != nil {
+			return err
+		}
+
+		addr := c.String("addr")
+		fmt.Printf("serving %s on http://%s\n", dir, addr)
+		fmt.Printf("add the repository with: curl -fsSL http://%s/sources.list > /etc/apt/sources.list.d/linuxpack.list\n", addr)
+
+		return http.ListenAndServe(addr, h)
+	},
+}
@@ -0,0 +1,210 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/config"
+	"github.com/common-fate/linuxpack/pkg/packager"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/urfave/cli/v2"
+)
+
+// repositoryFlags select the published repository and channel
+// which the snapshot commands operate on.
+var repositoryFlags = append([]cli.Flag{
+	&cli.StringFlag{Name: "bucket", Usage: "the S3 or GCS bucket, or Azure container, the repository is stored in"},
+	&cli.StringFlag{Name: "region", Usage: "the AWS region of the S3 bucket"},
+	&cli.StringFlag{Name: "channel", Usage: "the release channel (required unless the config file has a single channel)"},
+}, s3Flags...)
+
+var Snapshot = cli.Command{
+	Name:  "snapshot",
+	Usage: "Manage snapshots of the APT indexes of a channel",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Copy the published APT indexes of a channel into a new snapshot",
+			ArgsUsage: "<name>",
+			Flags: append(repositoryFlags,
+				&cli.PathFlag{Name: "out", Usage: "output directory (required unless set in the config file)"},
+			),
+			Action: func(c *cli.Context) error {
+				ctx := c.Context
+
+				name := c.Args().First()
+				if name == "" || c.NArg() > 1 {
+					return errors.New("usage: snapshot create <name>")
+				}
+
+				cfg, store, channel, err := openRepository(c)
+				if err != nil {
+					return err
+				}
+
+				out := pathOption(c, "out", cfg.Out)
+				if out == "" {
+					return errors.New("an output directory must be provided with --out or in the config file")
+				}
+
+				snapshot, err := packager.CreateSnapshot(ctx, store, out, channel, name, time.Now().UTC())
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("created snapshot %s of channel %s with %d files, sync %s to publish it\n", snapshot.Name, channel, len(snapshot.Files), out)
+				return nil
+			},
+		},
+		{
+			Name:      "publish",
+			Usage:     "Publish a snapshot as its own suite, which clients can pin to",
+			ArgsUsage: "<name>",
+			Flags: append(repositoryFlags,
+				&cli.StringFlag{Name: "suite", Usage: "the suite to publish the snapshot as (defaults to <channel>-<name>)"},
+				&cli.StringFlag{Name: "sign-key", Usage: "the ID of the GPG key to sign the suite's Release file with"},
+				&cli.PathFlag{Name: "out", Usage: "output directory (required unless set in the config file)"},
+			),
+			Action: func(c *cli.Context) error {
+				ctx := c.Context
+
+				name := c.Args().First()
+				if name == "" || c.NArg() > 1 {
+					return errors.New("usage: snapshot publish <name>")
+				}
+
+				cfg, store, channel, err := openRepository(c)
+				if err != nil {
+					return err
+				}
+
+				out := pathOption(c, "out", cfg.Out)
+				if out == "" {
+					return errors.New("an output directory must be provided with --out or in the config file")
+				}
+
+				suite := c.String("suite")
+				if suite == "" {
+					suite = channel + "-" + name
+				}
+
+				var signer signing.Signer
+				if keyID := stringOption(c, "sign-key", cfg.Signing.GPGKey); keyID != "" {
+					signer = signing.GPG{KeyID: keyID}
+				}
+
+				_, err = packager.PublishSnapshot(ctx, store, out, channel, name, suite, signer)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("published snapshot %s of channel %s as suite %s, sync %s to publish it\n", name, channel, suite, out)
+
+				printInvalidation(cfg, path.Join("/dists", suite, "*"))
+
+				return nil
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "List the snapshots of a channel",
+			Flags: repositoryFlags,
+			Action: func(c *cli.Context) error {
+				_, store, channel, err := openRepository(c)
+				if err != nil {
+					return err
+				}
+
+				index, err := packager.ReadSnapshots(c.Context, store, channel)
+				if err != nil {
+					return err
+				}
+
+				if len(index.Snapshots) == 0 {
+					fmt.Printf("channel %s has no snapshots\n", channel)
+					return nil
+				}
+
+				for _, s := range index.Snapshots {
+					fmt.Printf("%s\t%s\t%d files", s.Name, s.Created.Format(time.RFC3339), len(s.Files))
+					if len(s.Suites) > 0 {
+						fmt.Printf("\tpublished as %s", strings.Join(s.Suites, ", "))
+					}
+					fmt.Printf("\n")
+				}
+				return nil
+			},
+		},
+	},
+}
+
+var Rollback = cli.Command{
+	Name:      "rollback",
+	Usage:     "Republish the APT indexes of a channel from a snapshot",
+	ArgsUsage: "<name>",
+	Flags: append(repositoryFlags,
+		&cli.PathFlag{Name: "out", Usage: "output directory (required unless set in the config file)"},
+	),
+	Action: func(c *cli.Context) error {
+		ctx := c.Context
+
+		name := c.Args().First()
+		if name == "" || c.NArg() > 1 {
+			return errors.New("usage: rollback <name>")
+		}
+
+		cfg, store, channel, err := openRepository(c)
+		if err != nil {
+			return err
+		}
+
+		out := pathOption(c, "out", cfg.Out)
+		if out == "" {
+			return errors.New("an output directory must be provided with --out or in the config file")
+		}
+
+		snapshot, err := packager.Rollback(ctx, store, out, channel, name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("rolled back channel %s to snapshot %s (created %s), sync %s to publish it\n", channel, snapshot.Name, snapshot.Created.Format(time.RFC3339), out)
+
+		printInvalidation(cfg, path.Join("/dists", channel, "*"))
+
+		return nil
+	},
+}
+
+// openRepository loads the config file and returns the storage
+// and channel selected by the repository flags.
+func openRepository(c *cli.Context) (config.Config, storage.Storage, string, error) {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return config.Config{}, nil, "", err
+	}
+
+	channel := c.String("channel")
+	if channel == "" && len(cfg.Channels) == 1 {
+		channel = cfg.Channels[0].Name
+	}
+	if channel == "" {
+		return config.Config{}, nil, "", errors.New("a channel must be provided with --channel or in the config file")
+	}
+
+	_, err = cfg.Channel(channel)
+	if err != nil {
+		return config.Config{}, nil, "", err
+	}
+
+	store, err := openStorage(c, cfg)
+	if err != nil {
+		return config.Config{}, nil, "", err
+	}
+
+	return cfg, store, channel, nil
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/config"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/urfave/cli/v2"
+)
+
+// s3Flags configure the S3 client, so that S3-compatible services
+// such as MinIO, Cloudflare R2 and Ceph can be used.
+var s3Flags = []cli.Flag{
+	&cli.StringFlag{Name: "endpoint", Usage: "the URL of an S3-compatible service, such as http://localhost:9000 for MinIO, or of a GCS or Azure emulator"},
+	&cli.BoolFlag{Name: "path-style", Usage: "address the bucket in the URL path, as most S3-compatible services require"},
+	&cli.StringFlag{Name: "profile", Usage: "the AWS profile to load credentials from"},
+}
+
+// s3Options returns the options for the S3 client from the
+// flags, falling back to the storage section of the config file.
+func s3Options(c *cli.Context, cfg config.Config, bucket string) storage.S3Options {
+	return storage.S3Options{
+		Bucket:       bucket,
+		Region:       stringOption(c, "region", cfg.Storage.Region),
+		Endpoint:     stringOption(c, "endpoint", cfg.Storage.Endpoint),
+		UsePathStyle: boolOption(c, "path-style", &cfg.Storage.PathStyle),
+		Profile:      stringOption(c, "profile", cfg.Storage.Profile),
+	}
+}
+
+// openStorage returns the storage configured by the flags and the storage
+// section of the config file. For Azure, --bucket overrides the container.
+func openStorage(c *cli.Context, cfg config.Config) (storage.Storage, error) {
+	switch cfg.Storage.Type {
+	case "local":
+		return storage.Local{Dir: cfg.Storage.Dir}, nil
+	case "azure":
+		return storage.NewAzure(azureOptions(c, cfg, cfg.Storage.Account, stringOption(c, "bucket", cfg.Storage.Container)))
+	}
+
+	bucket := stringOption(c, "bucket", cfg.Storage.Bucket)
+	if bucket == "" {
+		return nil, errors.New("a bucket must be provided with --bucket or in the config file")
+	}
+
+	if cfg.Storage.Type == "gcs" {
+		return storage.NewGCS(c.Context, storage.GCSOptions{Bucket: bucket, Endpoint: stringOption(c, "endpoint", cfg.Storage.Endpoint)})
+	}
+	return storage.NewS3(c.Context, s3Options(c, cfg, bucket))
+}
+
+// azureOptions returns the options for an Azure container. The account key
+// and SAS token are read from the environment rather than the config file,
+// so that they aren't committed with it.
+func azureOptions(c *cli.Context, cfg config.Config, account string, container string) storage.AzureOptions {
+	return storage.AzureOptions{
+		Account:   account,
+		Container: container,
+		Endpoint:  stringOption(c, "endpoint", cfg.Storage.Endpoint),
+		Key:       os.Getenv("AZURE_STORAGE_KEY"),
+		SASToken:  os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+	}
+}
+
+// storageLocation returns the location of the configured storage, in
+// the form accepted by openLocation, or an empty string if there is none.
+func storageLocation(cfg config.Config) string {
+	switch cfg.Storage.Type {
+	case "local":
+		return cfg.Storage.Dir
+	case "azure":
+		return fmt.Sprintf("azblob://%s/%s", cfg.Storage.Account, cfg.Storage.Container)
+	case "gcs":
+		if cfg.Storage.Bucket != "" {
+			return "gs://" + cfg.Storage.Bucket
+		}
+	default:
+		if cfg.Storage.Bucket != "" {
+			return "s3://" + cfg.Storage.Bucket
+		}
+	}
+	return ""
+}
+
+// openLocation returns the storage for a repository location, which is an
+// S3 bucket such as s3://example-bucket, a GCS bucket such as
+// gs://example-bucket, an Azure container such as
+// azblob://example-account/packages, or a local directory.
+func openLocation(c *cli.Context, cfg config.Config, location string) (storage.Storage, error) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		return storage.Local{Dir: location}, nil
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+	parts := strings.Split(rest, "/")
+
+	switch scheme {
+	case "s3", "gs":
+		if len(parts) != 1 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid location %q: expected %s://<bucket>", location, scheme)
+		}
+		if scheme == "gs" {
+			return storage.NewGCS(c.Context, storage.GCSOptions{Bucket: parts[0], Endpoint: stringOption(c, "endpoint", cfg.Storage.Endpoint)})
+		}
+		return storage.NewS3(c.Context, s3Options(c, cfg, parts[0]))
+	case "azblob":
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid location %q: expected azblob://<account>/<container>", location)
+		}
+		return storage.NewAzure(azureOptions(c, cfg, parts[0], parts[1]))
+	}
+
+	return nil, fmt.Errorf("invalid location %q: expected s3://, gs:// or azblob://", location)
+}
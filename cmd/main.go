@@ -12,8 +12,17 @@ func main() {
 	app := &cli.App{
 		Name:  "linuxpack",
 		Usage: "Package and publish an APT repository to S3 and CloudFront",
+		Flags: []cli.Flag{
+			command.ConfigFlag,
+		},
 		Commands: []*cli.Command{
 			&command.Package,
+			&command.Build,
+			&command.Lint,
+			&command.Diff,
+			&command.Snapshot,
+			&command.Rollback,
+			&command.Serve,
 		},
 	}
 
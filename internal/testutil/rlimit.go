@@ -0,0 +1,33 @@
+//go:build unix
+
+// Package testutil contains helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"syscall"
+	"testing"
+)
+
+// SetNoFileLimit lowers the limit on open files for the rest of the test.
+// The original limit is restored when the test finishes.
+func SetNoFileLimit(t testing.TB, limit uint64) {
+	t.Helper()
+
+	var orig syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lowered := orig
+	lowered.Cur = limit
+	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &orig); err != nil {
+			t.Errorf("error restoring RLIMIT_NOFILE: %s", err)
+		}
+	})
+}
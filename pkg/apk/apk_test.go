@@ -0,0 +1,175 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// gzipSegment writes a tar segment without an end-of-archive marker
+// as a separate gzip stream, as abuild does.
+func gzipSegment(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, data := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Format: tar.FormatUSTAR})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testPackage(t *testing.T) (apk []byte, control []byte) {
+	pkginfo := `# Generated by abuild
+pkgname = granted
+pkgver = 0.27.5-r0
+pkgdesc = The easiest way to access your cloud.
+url = https://granted.dev
+builddate = 1700000000
+size = 38697
+arch = x86_64
+origin = granted
+license = MIT
+depend = so:libc.musl-x86_64.so.1
+provides = cmd:granted=0.27.5-r0
+`
+	signature := gzipSegment(t, map[string]string{".SIGN.RSA.test.rsa.pub": "signature"})
+	control = gzipSegment(t, map[string]string{".PKGINFO": pkginfo})
+	data := gzipSegment(t, map[string]string{"usr/bin/granted": "#!/bin/sh\n"})
+
+	return bytes.Join([][]byte{signature, control, data}, nil), control
+}
+
+func TestRead(t *testing.T) {
+	data, control := testPackage(t)
+
+	got, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha1.Sum(control)
+	want := &Package{
+		Name:            "granted",
+		Version:         "0.27.5-r0",
+		Arch:            "x86_64",
+		Description:     "The easiest way to access your cloud.",
+		URL:             "https://granted.dev",
+		License:         "MIT",
+		Origin:          "granted",
+		BuildDate:       1700000000,
+		InstalledSize:   38697,
+		Depends:         []string{"so:libc.musl-x86_64.so.1"},
+		Provides:        []string{"cmd:granted=0.27.5-r0"},
+		ControlChecksum: sum[:],
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIndex_SignedArchive(t *testing.T) {
+	data, _ := testPackage(t)
+
+	pkg, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing, err := ReadIndex(strings.NewReader(`C:Q1abc=
+P:granted
+V:0.27.4-r0
+A:x86_64
+S:100
+I:200
+T:The easiest way to access your cloud.
+U:https://granted.dev
+L:MIT
+k:100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing.Add(pkg, int64(len(data)))
+
+	var archive bytes.Buffer
+	if err := existing.WriteArchive(&archive, "stable", time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Signer{Key: key, KeyName: "test.rsa.pub"}
+
+	signed, err := s.Sign(archive.Bytes(), time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the signed archive must be readable as a single tar stream
+	got, err := ReadIndexArchive(bytes.NewReader(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(existing, got); diff != "" {
+		t.Errorf("ReadIndexArchive() mismatch (-want +got):\n%s", diff)
+	}
+
+	// unknown fields such as k: are preserved
+	old := got.Entries[indexKey{Name: "granted", Version: "0.27.4-r0"}]
+	if old.Get("k") != "100" {
+		t.Errorf("expected k field to be preserved, got %v", old)
+	}
+
+	// the signature covers the unsigned archive
+	gz, err := gzip.NewReader(bytes.NewReader(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz.Multistream(false)
+	tr := tar.NewReader(gz)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != ".SIGN.RSA.test.rsa.pub" {
+		t.Fatalf("unexpected signature entry %q", hdr.Name)
+	}
+	sig, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha1.Sum(archive.Bytes())
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], sig); err != nil {
+		t.Errorf("signature verification failed: %v", err)
+	}
+}
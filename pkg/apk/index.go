@@ -0,0 +1,236 @@
+package apk
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Index is an APKINDEX for a single architecture.
+type Index struct {
+	// Entries maps a package name and version to its index entry.
+	Entries map[indexKey]Entry
+}
+
+type indexKey struct {
+	Name    string
+	Version string
+}
+
+// Entry is a package's fields in an APKINDEX, in the order they appear.
+// Fields are kept as-is so that fields which linuxpack doesn't understand
+// are preserved when the index is rewritten.
+type Entry []Field
+
+// Field is a single field of an APKINDEX entry, such as P:granted.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Get returns the value of the first field with the given key.
+func (e Entry) Get(key string) string {
+	for _, f := range e {
+		if f.Key == key {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Add adds a package to the index, replacing any existing
+// entry with the same name and version.
+func (idx *Index) Add(p *Package, size int64) {
+	e := Entry{
+		{Key: "C", Value: p.checksumField()},
+		{Key: "P", Value: p.Name},
+		{Key: "V", Value: p.Version},
+		{Key: "A", Value: p.Arch},
+		{Key: "S", Value: strconv.FormatInt(size, 10)},
+		{Key: "I", Value: strconv.FormatInt(p.InstalledSize, 10)},
+		{Key: "T", Value: p.Description},
+		{Key: "U", Value: p.URL},
+		{Key: "L", Value: p.License},
+	}
+
+	optional := []Field{
+		{Key: "o", Value: p.Origin},
+		{Key: "m", Value: p.Maintainer},
+		{Key: "t", Value: formatInt(p.BuildDate)},
+		{Key: "c", Value: p.Commit},
+		{Key: "D", Value: strings.Join(p.Depends, " ")},
+		{Key: "p", Value: strings.Join(p.Provides, " ")},
+		{Key: "i", Value: strings.Join(p.InstallIf, " ")},
+	}
+	for _, f := range optional {
+		if f.Value != "" {
+			e = append(e, f)
+		}
+	}
+
+	idx.add(e)
+}
+
+func formatInt(i int64) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.FormatInt(i, 10)
+}
+
+func (idx *Index) add(e Entry) {
+	if idx.Entries == nil {
+		idx.Entries = map[indexKey]Entry{}
+	}
+	idx.Entries[indexKey{Name: e.Get("P"), Version: e.Get("V")}] = e
+}
+
+// Write writes the APKINDEX text, sorted by package name and version.
+func (idx *Index) Write(w io.Writer) error {
+	var keys []indexKey
+	for k := range idx.Entries {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b indexKey) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Version, b.Version)
+	})
+
+	for _, k := range keys {
+		for _, f := range idx.Entries[k] {
+			_, err := fmt.Fprintf(w, "%s:%s\n", f.Key, f.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadIndex reads APKINDEX text.
+func ReadIndex(r io.Reader) (Index, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var idx Index
+	var current Entry
+
+	var lineNum int
+	for sc.Scan() {
+		lineNum++
+		line := sc.Text()
+
+		if line == "" {
+			if len(current) > 0 {
+				idx.add(current)
+			}
+			current = nil
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return Index{}, fmt.Errorf("invalid line %v: did not contain a \":\" separator: %q", lineNum, line)
+		}
+
+		current = append(current, Field{Key: key, Value: value})
+	}
+
+	if err := sc.Err(); err != nil {
+		return Index{}, err
+	}
+
+	if len(current) > 0 {
+		idx.add(current)
+	}
+
+	return idx, nil
+}
+
+// ReadIndexArchive reads the APKINDEX from an APKINDEX.tar.gz archive,
+// skipping the signature if one is present.
+func ReadIndexArchive(r io.Reader) (Index, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Index{}, err
+	}
+	defer gz.Close()
+
+	// the signature and index are separate gzip streams,
+	// which are read as a single tar archive
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return Index{}, nil
+		}
+		if err != nil {
+			return Index{}, err
+		}
+
+		if hdr.Name == "APKINDEX" {
+			return ReadIndex(tr)
+		}
+	}
+}
+
+// WriteArchive writes the unsigned APKINDEX.tar.gz archive.
+func (idx *Index) WriteArchive(w io.Writer, description string, modTime time.Time) error {
+	var index bytes.Buffer
+	err := idx.Write(&index)
+	if err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{name: "DESCRIPTION", data: []byte(description)},
+		{name: "APKINDEX", data: index.Bytes()},
+	}
+
+	for _, f := range files {
+		err = tw.WriteHeader(&tar.Header{
+			Name:    f.name,
+			Mode:    0644,
+			Size:    int64(len(f.data)),
+			ModTime: modTime,
+			Format:  tar.FormatUSTAR,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,202 @@
+package apk
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Package is the metadata read from an .apk package.
+type Package struct {
+	Name          string
+	Version       string
+	Arch          string
+	Description   string
+	URL           string
+	License       string
+	Origin        string
+	Maintainer    string
+	Commit        string
+	BuildDate     int64
+	InstalledSize int64
+	Depends       []string
+	Provides      []string
+	InstallIf     []string
+	// ControlChecksum is the SHA1 checksum of the control segment,
+	// used as the package's identity in APKINDEX.
+	ControlChecksum []byte
+}
+
+// segmentReader reads consecutive gzip streams from an .apk, hashing
+// the compressed bytes of the current segment.
+//
+// gzip.Reader reads byte-by-byte from an io.ByteReader, so it never reads
+// past the end of a gzip stream and segment boundaries are preserved.
+type segmentReader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (s *segmentReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.h.Write(p[:n])
+	return n, err
+}
+
+func (s *segmentReader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+// Read reads the metadata of an .apk package from r. The signature and
+// control segments are read; the data segment is left unread.
+func Read(r io.Reader) (*Package, error) {
+	sr := segmentReader{r: bufio.NewReader(r), h: sha1.New()}
+
+	var pkginfo []byte
+
+	// the control segment is preceded by an optional signature segment
+	for i := 0; i < 2 && pkginfo == nil; i++ {
+		sr.h.Reset()
+
+		names, info, err := readSegment(&sr)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(names) > 0 && strings.HasPrefix(names[0], ".SIGN.") {
+			continue
+		}
+		if info == nil {
+			return nil, errors.New("invalid .apk package: control segment has no .PKGINFO")
+		}
+		pkginfo = info
+	}
+
+	if pkginfo == nil {
+		return nil, errors.New("invalid .apk package: no control segment")
+	}
+
+	p, err := parsePKGINFO(pkginfo)
+	if err != nil {
+		return nil, err
+	}
+	p.ControlChecksum = sr.h.Sum(nil)
+
+	return p, nil
+}
+
+// readSegment reads a single gzip stream containing a tar archive,
+// returning the names of its entries and the contents of .PKGINFO.
+func readSegment(r *segmentReader) ([]string, []byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading .apk segment: %w", err)
+	}
+	gz.Multistream(false)
+
+	var names []string
+	var pkginfo []byte
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading .apk segment: %w", err)
+		}
+
+		names = append(names, hdr.Name)
+
+		if hdr.Name == ".PKGINFO" {
+			pkginfo, err = io.ReadAll(tr)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// read to the end of the gzip stream so that
+	// the next segment starts at the right offset
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return nil, nil, err
+	}
+
+	return names, pkginfo, gz.Close()
+}
+
+func parsePKGINFO(data []byte) (*Package, error) {
+	var p Package
+
+	for i, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, " = ")
+		if !found {
+			return nil, fmt.Errorf("invalid .PKGINFO line %v: did not contain a \" = \" separator: %q", i+1, line)
+		}
+
+		switch key {
+		case "pkgname":
+			p.Name = value
+		case "pkgver":
+			p.Version = value
+		case "arch":
+			p.Arch = value
+		case "pkgdesc":
+			p.Description = value
+		case "url":
+			p.URL = value
+		case "license":
+			p.License = value
+		case "origin":
+			p.Origin = value
+		case "maintainer":
+			p.Maintainer = value
+		case "commit":
+			p.Commit = value
+		case "builddate":
+			p.BuildDate, _ = strconv.ParseInt(value, 10, 64)
+		case "size":
+			p.InstalledSize, _ = strconv.ParseInt(value, 10, 64)
+		case "depend":
+			p.Depends = append(p.Depends, value)
+		case "provides":
+			p.Provides = append(p.Provides, value)
+		case "install_if":
+			p.InstallIf = append(p.InstallIf, strings.Fields(value)...)
+		}
+	}
+
+	if p.Name == "" || p.Version == "" || p.Arch == "" {
+		return nil, errors.New("invalid .PKGINFO: missing pkgname, pkgver or arch")
+	}
+
+	return &p, nil
+}
+
+// Filename returns the file name which apk expects the package to be
+// published under, relative to the architecture directory.
+func (p *Package) Filename() string {
+	return fmt.Sprintf("%s-%s.apk", p.Name, p.Version)
+}
+
+// checksumField returns the APKINDEX C: field for the package.
+func (p *Package) checksumField() string {
+	return "Q1" + base64.StdEncoding.EncodeToString(p.ControlChecksum)
+}
@@ -0,0 +1,193 @@
+package apk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/plan"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// Packager publishes .apk packages to an Alpine repository.
+//
+// Each channel is a separate repository, stored under alpine/<channel>/,
+// with a directory per architecture containing the packages and APKINDEX.
+type Packager struct {
+	// Storage holds the published repository, which
+	// the new packages are merged with.
+	Storage      storage.Storage
+	OutputFolder string
+	Channel      string
+	Description  string
+	Files        []string
+	// Signer signs the APKINDEX, if set.
+	Signer *Signer
+	// DryRun skips copying packages into the output folder and signing
+	// the APKINDEX, so that changes can be planned without publishing them.
+	DryRun bool
+	// Plan records the changes made to the repository, if set.
+	Plan *plan.Plan
+}
+
+func (p Packager) Package(ctx context.Context) error {
+	repoPath := path.Join("alpine", p.Channel)
+	outPath := filepath.Join(p.OutputFolder, "alpine", p.Channel)
+	var err error
+
+	// map of architecture -> index
+	indexes := map[string]Index{}
+
+	for _, fileName := range p.Files {
+		pkg, size, err := p.addFile(repoPath, outPath, fileName)
+		if err != nil {
+			return err
+		}
+
+		idx, ok := indexes[pkg.Arch]
+		if !ok {
+			// only the indexes for architectures which have new packages
+			// are read, as the other indexes are left unchanged
+			idx, err = p.readExistingIndex(ctx, path.Join(repoPath, pkg.Arch, "APKINDEX.tar.gz"))
+			if err != nil {
+				return err
+			}
+		}
+
+		change := plan.PackageChange{
+			Action:  plan.Added,
+			Format:  "apk",
+			Index:   path.Join(repoPath, pkg.Arch, "APKINDEX.tar.gz"),
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			Arch:    pkg.Arch,
+		}
+		if _, ok := idx.Entries[indexKey{Name: pkg.Name, Version: pkg.Version}]; ok {
+			change.Action = plan.Replaced
+		}
+		p.Plan.AddPackage(change)
+
+		idx.Add(pkg, size)
+		indexes[pkg.Arch] = idx
+	}
+
+	var architectures []string
+	for arch := range indexes {
+		architectures = append(architectures, arch)
+	}
+	slices.Sort(architectures)
+
+	now := time.Now().UTC()
+
+	for _, arch := range architectures {
+		idx := indexes[arch]
+
+		var buf bytes.Buffer
+		err = idx.WriteArchive(&buf, p.Description, now)
+		if err != nil {
+			return err
+		}
+
+		archive := buf.Bytes()
+		if p.Signer != nil && !p.DryRun {
+			archive, err = p.Signer.Sign(archive, now)
+			if err != nil {
+				return fmt.Errorf("error signing APKINDEX: %w", err)
+			}
+		}
+
+		err = os.MkdirAll(filepath.Join(outPath, arch), 0755)
+		if err != nil {
+			return err
+		}
+
+		indexPath := filepath.Join(outPath, arch, "APKINDEX.tar.gz")
+		err = os.WriteFile(indexPath, archive, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFile reads an .apk package and copies it into the output folder.
+func (p Packager) addFile(repoPath string, outPath string, fileName string) (*Package, int64, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the file is copied in the same pass as reading the package. The
+	// path depends on the package's architecture, name and version, so
+	// the file is copied to a temporary file which is renamed once the
+	// package is read.
+	var r io.Reader = file
+	var tmp *output.TempFile
+	if !p.DryRun {
+		tmp, err = output.CreateTemp(outPath, fileInfo.Name())
+		if err != nil {
+			return nil, 0, err
+		}
+		defer tmp.Cleanup()
+
+		r = io.TeeReader(file, tmp)
+	}
+
+	pkg, err := Read(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	// apk fetches packages from the architecture directory
+	// by name and version, regardless of the original file name
+	p.Plan.AddUpload(path.Join(repoPath, pkg.Arch, pkg.Filename()), fileInfo.Size())
+
+	if p.DryRun {
+		return pkg, fileInfo.Size(), nil
+	}
+
+	pathToCopy := filepath.Join(outPath, pkg.Arch, pkg.Filename())
+	fmt.Printf("adding %s %s as %s\n", pkg.Name, pkg.Version, pathToCopy)
+
+	// Read may not consume the whole file
+	if _, err := io.Copy(tmp, file); err != nil {
+		return nil, 0, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	return pkg, fileInfo.Size(), tmp.Commit(pathToCopy)
+}
+
+// readExistingIndex reads an existing APKINDEX.tar.gz from storage.
+func (p Packager) readExistingIndex(ctx context.Context, key string) (Index, error) {
+	fmt.Printf("reading existing index from %s\n", p.Storage.URL(key))
+
+	body, err := p.Storage.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		fmt.Printf("no index found\n")
+		return Index{}, nil
+	}
+	if err != nil {
+		return Index{}, err
+	}
+	defer body.Close()
+
+	idx, err := ReadIndexArchive(body)
+	if err != nil {
+		return Index{}, fmt.Errorf("error reading %s: %w", key, err)
+	}
+	return idx, nil
+}
@@ -0,0 +1,118 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Signer signs APKINDEX archives with an RSA key, following the
+// conventions of Alpine's abuild-sign.
+type Signer struct {
+	Key *rsa.PrivateKey
+	// KeyName is the file name of the public key as installed in
+	// /etc/apk/keys on clients, such as "linuxpack-6512f3a1.rsa.pub".
+	KeyName string
+}
+
+// LoadSigner loads a PEM encoded RSA private key. If keyName is empty,
+// the key name defaults to the base name of the key file with a .pub
+// suffix, matching the output of abuild-keygen.
+func LoadSigner(path string, keyName string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key", path)
+	}
+
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		key, ok = k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("APK signing keys must be RSA keys")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	if keyName == "" {
+		keyName = filepath.Base(path) + ".pub"
+	}
+
+	return &Signer{Key: key, KeyName: keyName}, nil
+}
+
+// Sign signs an APKINDEX.tar.gz archive, returning the signed archive.
+//
+// The signature is an RSA PKCS#1 v1.5 signature over the SHA1 checksum of the
+// archive, stored in a .SIGN.RSA.<key name> tar entry. The entry is written as
+// a separate gzip stream, without a tar end-of-archive marker, and prepended
+// to the archive.
+func (s *Signer) Sign(archive []byte, modTime time.Time) ([]byte, error) {
+	digest := sha1.Sum(archive)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA1, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := tar.NewWriter(gz)
+	err = tw.WriteHeader(&tar.Header{
+		Name:    ".SIGN.RSA." + s.KeyName,
+		Mode:    0644,
+		Size:    int64(len(sig)),
+		ModTime: modTime,
+		Format:  tar.FormatUSTAR,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(sig); err != nil {
+		return nil, err
+	}
+
+	// Flush rather than Close, as the end-of-archive marker
+	// would hide the index entries which follow the signature
+	err = tw.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(buf.Bytes(), archive...), nil
+}
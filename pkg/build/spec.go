@@ -0,0 +1,434 @@
+// Package build builds .deb packages from a YAML spec, so that binaries
+// can be packaged and published without a separate packaging tool.
+package build
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/common-fate/linuxpack/pkg/deb"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes a .deb package to build.
+//
+// Environment variables in the spec, such as ${VERSION},
+// are expanded when it is loaded.
+type Spec struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Arch       string `yaml:"arch"`
+	Maintainer string `yaml:"maintainer"`
+	// Description is the package description. The first line is used
+	// as the synopsis and any following lines as the long description.
+	Description string `yaml:"description"`
+	Homepage    string `yaml:"homepage"`
+	Section     string `yaml:"section"`
+	Priority    string `yaml:"priority"`
+
+	PreDepends []string `yaml:"pre_depends"`
+	Depends    []string `yaml:"depends"`
+	Recommends []string `yaml:"recommends"`
+	Suggests   []string `yaml:"suggests"`
+	Conflicts  []string `yaml:"conflicts"`
+	Breaks     []string `yaml:"breaks"`
+	Replaces   []string `yaml:"replaces"`
+	Provides   []string `yaml:"provides"`
+
+	Contents []Content `yaml:"contents"`
+	// Conffiles are additional configuration files, beyond
+	// the contents with the config type.
+	Conffiles []string `yaml:"conffiles"`
+	Scripts   Scripts  `yaml:"scripts"`
+	Systemd   []Unit   `yaml:"systemd"`
+
+	// dir is the directory containing the spec,
+	// which relative paths are resolved against.
+	dir string
+}
+
+// Content maps a file on disk to a path in the package.
+type Content struct {
+	// Src is the path of the file on disk, or the target of a symlink.
+	Src string `yaml:"src"`
+	// Dst is the absolute path that the file is installed to.
+	Dst string `yaml:"dst"`
+	// Type is empty for a regular file, "config" for a configuration
+	// file, "symlink" for a symlink, or "dir" for an empty directory.
+	Type string `yaml:"type"`
+	// Mode is the octal file mode, such as 0755. It defaults to the
+	// mode of the file on disk.
+	Mode string `yaml:"mode"`
+}
+
+// Scripts are the paths of the package's maintainer scripts.
+type Scripts struct {
+	Preinst  string `yaml:"preinst"`
+	Postinst string `yaml:"postinst"`
+	Prerm    string `yaml:"prerm"`
+	Postrm   string `yaml:"postrm"`
+}
+
+// Unit is a systemd unit installed by the package.
+type Unit struct {
+	// Src is the path of the unit file on disk.
+	Src string `yaml:"src"`
+	// Enable enables the unit when the package is installed.
+	Enable bool `yaml:"enable"`
+	// Start starts or restarts the unit when the package is installed or upgraded.
+	Start bool `yaml:"start"`
+}
+
+// name returns the name of the unit, such as granted.service.
+func (u Unit) name() string {
+	return filepath.Base(u.Src)
+}
+
+// LoadSpec loads a spec from a YAML file.
+func LoadSpec(fileName string) (Spec, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	s, err := ParseSpec(data)
+	if err != nil {
+		return Spec{}, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	s.dir = filepath.Dir(fileName)
+	return s, nil
+}
+
+// ParseSpec parses a YAML spec. Relative paths in the
+// spec are resolved against the working directory.
+func ParseSpec(data []byte) (Spec, error) {
+	var s Spec
+
+	dec := yaml.NewDecoder(strings.NewReader(os.ExpandEnv(string(data))))
+	dec.KnownFields(true)
+
+	err := dec.Decode(&s)
+	if err != nil && err != io.EOF {
+		return Spec{}, err
+	}
+
+	return s, s.Validate()
+}
+
+// Validate returns an error if the spec is missing required fields
+// or contains invalid contents.
+func (s Spec) Validate() error {
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"name", s.Name},
+		{"version", s.Version},
+		{"arch", s.Arch},
+		{"maintainer", s.Maintainer},
+		{"description", s.Description},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("spec is missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	for _, c := range s.Contents {
+		if !path.IsAbs(c.Dst) {
+			return fmt.Errorf("invalid contents: dst %q must be an absolute path", c.Dst)
+		}
+
+		switch c.Type {
+		case "", "config", "symlink":
+			if c.Src == "" {
+				return fmt.Errorf("invalid contents: %s has no src", c.Dst)
+			}
+		case "dir":
+		default:
+			return fmt.Errorf("invalid contents: %s has unknown type %q (expected config, symlink or dir)", c.Dst, c.Type)
+		}
+
+		if c.Mode != "" {
+			if _, err := strconv.ParseUint(c.Mode, 8, 32); err != nil {
+				return fmt.Errorf("invalid contents: %s has invalid mode %q", c.Dst, c.Mode)
+			}
+		}
+	}
+
+	for _, u := range s.Systemd {
+		if u.Src == "" {
+			return errors.New("invalid systemd unit: no src")
+		}
+	}
+
+	return nil
+}
+
+// Filename returns the conventional file name of the package,
+// such as granted_0.27.5_amd64.deb.
+func (s Spec) Filename() string {
+	version := s.Version
+	// the epoch is not included in file names
+	if _, after, found := strings.Cut(version, ":"); found {
+		version = after
+	}
+	return fmt.Sprintf("%s_%s_%s.deb", s.Name, version, s.Arch)
+}
+
+// resolve resolves a path in the spec relative to the spec's directory.
+func (s Spec) resolve(p string) string {
+	if filepath.IsAbs(p) || s.dir == "" {
+		return p
+	}
+	return filepath.Join(s.dir, p)
+}
+
+// Build writes the package as a .deb to w.
+func (s Spec) Build(w io.Writer, modTime time.Time) error {
+	a, err := s.archive(modTime)
+	if err != nil {
+		return err
+	}
+	return deb.Write(w, a)
+}
+
+// archive converts the spec into the contents of a .deb package.
+func (s Spec) archive(modTime time.Time) (deb.Archive, error) {
+	synopsis, long, _ := strings.Cut(strings.TrimSpace(s.Description), "\n")
+
+	a := deb.Archive{
+		Control: control.Control{
+			Package:      s.Name,
+			Version:      s.Version,
+			Architecture: s.Arch,
+			Maintainer:   s.Maintainer,
+			Section:      s.Section,
+			Priority:     s.Priority,
+			Homepage:     s.Homepage,
+			PreDepends:   strings.Join(s.PreDepends, ", "),
+			Depends:      strings.Join(s.Depends, ", "),
+			Recommends:   strings.Join(s.Recommends, ", "),
+			Suggests:     strings.Join(s.Suggests, ", "),
+			Conflicts:    strings.Join(s.Conflicts, ", "),
+			Breaks:       strings.Join(s.Breaks, ", "),
+			Replaces:     strings.Join(s.Replaces, ", "),
+			Provides:     strings.Join(s.Provides, ", "),
+			Description:  control.FormatDescription(strings.TrimSpace(synopsis), long),
+		},
+		Conffiles: s.Conffiles,
+		Scripts:   map[string][]byte{},
+		ModTime:   modTime,
+	}
+
+	for _, c := range s.Contents {
+		f, err := s.file(c)
+		if err != nil {
+			return deb.Archive{}, err
+		}
+		a.Files = append(a.Files, f)
+
+		if c.Type == "config" {
+			a.Conffiles = append(a.Conffiles, c.Dst)
+		}
+	}
+
+	for _, u := range s.Systemd {
+		src := s.resolve(u.Src)
+		info, err := os.Stat(src)
+		if err != nil {
+			return deb.Archive{}, err
+		}
+		a.Files = append(a.Files, deb.File{
+			Name:   "/lib/systemd/system/" + u.name(),
+			Mode:   info.Mode().Perm(),
+			Source: src,
+		})
+	}
+
+	snippets := systemdScripts(s.Systemd)
+
+	for _, script := range []struct {
+		name string
+		path string
+	}{
+		{"preinst", s.Scripts.Preinst},
+		{"postinst", s.Scripts.Postinst},
+		{"prerm", s.Scripts.Prerm},
+		{"postrm", s.Scripts.Postrm},
+	} {
+		var body []byte
+		if script.path != "" {
+			var err error
+			body, err = os.ReadFile(s.resolve(script.path))
+			if err != nil {
+				return deb.Archive{}, err
+			}
+		}
+
+		data, err := maintainerScript(script.name, body, snippets[script.name])
+		if err != nil {
+			return deb.Archive{}, err
+		}
+		if data != nil {
+			a.Scripts[script.name] = data
+		}
+	}
+
+	return a, nil
+}
+
+// file converts a content entry into a file in the package.
+func (s Spec) file(c Content) (deb.File, error) {
+	f := deb.File{Name: c.Dst}
+
+	var mode fs.FileMode
+	if c.Mode != "" {
+		m, _ := strconv.ParseUint(c.Mode, 8, 32)
+		mode = fs.FileMode(m)
+	}
+
+	switch c.Type {
+	case "symlink":
+		f.LinkTarget = c.Src
+		return f, nil
+	case "dir":
+		f.Dir = true
+		return f, nil
+	}
+
+	f.Source = s.resolve(c.Src)
+	info, err := os.Stat(f.Source)
+	if err != nil {
+		return deb.File{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return deb.File{}, fmt.Errorf("%s is not a regular file", f.Source)
+	}
+
+	f.Mode = info.Mode().Perm()
+	if c.Mode != "" {
+		f.Mode = mode
+	}
+
+	return f, nil
+}
+
+// shells are the interpreters which can run the generated snippets.
+var shells = []string{"sh", "bash", "dash", "ksh", "zsh"}
+
+// maintainerScript combines a maintainer script with the generated
+// snippet for the script, returning nil if both are empty. The script's
+// interpreter is kept, so it must be a shell. As with debhelper, the
+// snippet runs after the script's own body in postinst and postrm, so
+// that units are started once the package is set up, and before it in
+// prerm, so that units are stopped before the package is torn down.
+func maintainerScript(name string, body []byte, snippet string) ([]byte, error) {
+	if snippet == "" {
+		return body, nil
+	}
+	if len(body) == 0 {
+		return []byte("#!/bin/sh\nset -e\n\n" + snippet), nil
+	}
+
+	interpreter := "#!/bin/sh"
+	script := string(body)
+	if strings.HasPrefix(script, "#!") {
+		interpreter, script, _ = strings.Cut(script, "\n")
+	}
+
+	var shell string
+	fields := strings.Fields(strings.TrimPrefix(interpreter, "#!"))
+	if len(fields) > 0 {
+		shell = path.Base(fields[0])
+	}
+	// the interpreter may be run with env, such as #!/usr/bin/env bash
+	if shell == "env" && len(fields) > 1 {
+		shell = path.Base(fields[1])
+	}
+	if !slices.Contains(shells, shell) {
+		return nil, fmt.Errorf("the %s script must be a shell script to add the systemd snippets, but its interpreter is %q", name, interpreter)
+	}
+
+	script = strings.TrimSpace(script)
+
+	var b strings.Builder
+	b.WriteString(interpreter + "\n\n")
+	b.WriteString("# added by linuxpack for the package's systemd units\n")
+	b.WriteString("linuxpack_systemd() {\n" + snippet + "}\n\n")
+
+	if name == "prerm" {
+		b.WriteString("linuxpack_systemd \"$@\"\n\n" + script + "\n")
+		return []byte(b.String()), nil
+	}
+
+	// the script is run in a subshell, so that the snippet
+	// still runs if the script exits early with exit 0
+	b.WriteString("(\n" + script + "\n)\n")
+	b.WriteString("linuxpack_status=$?\n")
+	b.WriteString("if [ \"$linuxpack_status\" -ne 0 ]; then\n\texit \"$linuxpack_status\"\nfi\n\n")
+	b.WriteString("linuxpack_systemd \"$@\"\n")
+	return []byte(b.String()), nil
+}
+
+// systemdScripts returns the maintainer script snippets which
+// reload, enable, start and stop the given systemd units.
+func systemdScripts(units []Unit) map[string]string {
+	if len(units) == 0 {
+		return nil
+	}
+
+	var enable, start, all []string
+	for _, u := range units {
+		all = append(all, u.name())
+		if u.Enable {
+			enable = append(enable, u.name())
+		}
+		if u.Start {
+			start = append(start, u.name())
+		}
+	}
+
+	var postinst strings.Builder
+	postinst.WriteString("if [ \"$1\" = \"configure\" ] || [ \"$1\" = \"abort-upgrade\" ]; then\n")
+	postinst.WriteString("\tif [ -d /run/systemd/system ]; then\n")
+	postinst.WriteString("\t\tsystemctl daemon-reload >/dev/null || true\n")
+	if len(enable) > 0 {
+		fmt.Fprintf(&postinst, "\t\tsystemctl enable %s >/dev/null || true\n", strings.Join(enable, " "))
+	}
+	if len(start) > 0 {
+		fmt.Fprintf(&postinst, "\t\tsystemctl restart %s >/dev/null || true\n", strings.Join(start, " "))
+	}
+	postinst.WriteString("\tfi\nfi\n")
+
+	prerm := fmt.Sprintf(`if [ -d /run/systemd/system ] && [ "$1" = "remove" ]; then
+	systemctl stop %[1]s >/dev/null || true
+	systemctl disable %[1]s >/dev/null || true
+fi
+`, strings.Join(all, " "))
+
+	postrm := `if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload >/dev/null || true
+fi
+`
+
+	return map[string]string{
+		"postinst": postinst.String(),
+		"prerm":    prerm,
+		"postrm":   postrm,
+	}
+}
@@ -0,0 +1,227 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSpec(t *testing.T) {
+	type testcase struct {
+		name    string
+		give    string
+		want    Spec
+		wantErr string
+	}
+
+	testcases := []testcase{
+		{
+			name: "ok",
+			give: `name: granted
+version: 0.27.5
+arch: amd64
+maintainer: Common Fate <hello@commonfate.io>
+description: The easiest way to access your cloud.
+depends: [libc6]
+contents:
+  - src: ./granted.conf
+    dst: /etc/granted/granted.conf
+    type: config
+    mode: 0600
+`,
+			want: Spec{
+				Name:        "granted",
+				Version:     "0.27.5",
+				Arch:        "amd64",
+				Maintainer:  "Common Fate <hello@commonfate.io>",
+				Description: "The easiest way to access your cloud.",
+				Depends:     []string{"libc6"},
+				Contents:    []Content{{Src: "./granted.conf", Dst: "/etc/granted/granted.conf", Type: "config", Mode: "0600"}},
+			},
+		},
+		{
+			name:    "missing_fields",
+			give:    "name: granted\n",
+			wantErr: "spec is missing required fields: version, arch, maintainer, description",
+		},
+		{
+			name: "relative_dst",
+			give: `name: granted
+version: 0.27.5
+arch: amd64
+maintainer: Common Fate <hello@commonfate.io>
+description: The easiest way to access your cloud.
+contents:
+  - src: ./granted
+    dst: usr/bin/granted
+`,
+			wantErr: `invalid contents: dst "usr/bin/granted" must be an absolute path`,
+		},
+		{
+			name: "invalid_mode",
+			give: `name: granted
+version: 0.27.5
+arch: amd64
+maintainer: Common Fate <hello@commonfate.io>
+description: The easiest way to access your cloud.
+contents:
+  - src: ./granted
+    dst: /usr/bin/granted
+    mode: 0999
+`,
+			wantErr: `invalid contents: /usr/bin/granted has invalid mode "0999"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSpec([]byte(tc.give))
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(Spec{})); diff != "" {
+				t.Errorf("ParseSpec() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMaintainerScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		body    string
+		snippet string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "no_script",
+			snippet: "echo snippet\n",
+			want:    "#!/bin/sh\nset -e\n\necho snippet\n",
+		},
+		{
+			// the snippet runs after the script, which
+			// runs in a subshell so it may exit early
+			name:    "keeps_interpreter",
+			body:    "#!/bin/bash\necho hi\nexit 0\n",
+			snippet: "echo snippet\n",
+			want:    "#!/bin/bash\n\n# added by linuxpack for the package's systemd units\nlinuxpack_systemd() {\necho snippet\n}\n\n(\necho hi\nexit 0\n)\nlinuxpack_status=$?\nif [ \"$linuxpack_status\" -ne 0 ]; then\n\texit \"$linuxpack_status\"\nfi\n\nlinuxpack_systemd \"$@\"\n",
+		},
+		{
+			name:    "env_interpreter",
+			body:    "#!/usr/bin/env bash\necho hi\n",
+			snippet: "echo snippet\n",
+			want:    "#!/usr/bin/env bash\n\n# added by linuxpack for the package's systemd units\nlinuxpack_systemd() {\necho snippet\n}\n\n(\necho hi\n)\nlinuxpack_status=$?\nif [ \"$linuxpack_status\" -ne 0 ]; then\n\texit \"$linuxpack_status\"\nfi\n\nlinuxpack_systemd \"$@\"\n",
+		},
+		{
+			name:    "without_interpreter",
+			body:    "echo hi\n",
+			snippet: "echo snippet\n",
+			want:    "#!/bin/sh\n\n# added by linuxpack for the package's systemd units\nlinuxpack_systemd() {\necho snippet\n}\n\n(\necho hi\n)\nlinuxpack_status=$?\nif [ \"$linuxpack_status\" -ne 0 ]; then\n\texit \"$linuxpack_status\"\nfi\n\nlinuxpack_systemd \"$@\"\n",
+		},
+		{
+			// units are stopped before the script runs
+			name:    "prerm",
+			script:  "prerm",
+			body:    "#!/bin/sh\necho hi\n",
+			snippet: "echo snippet\n",
+			want:    "#!/bin/sh\n\n# added by linuxpack for the package's systemd units\nlinuxpack_systemd() {\necho snippet\n}\n\nlinuxpack_systemd \"$@\"\n\necho hi\n",
+		},
+		{
+			name:    "not_a_shell",
+			body:    "#!/usr/bin/python3\nprint('hi')\n",
+			snippet: "echo snippet\n",
+			wantErr: `the postinst script must be a shell script to add the systemd snippets, but its interpreter is "#!/usr/bin/python3"`,
+		},
+		{
+			name: "no_snippet",
+			body: "#!/usr/bin/python3\nprint('hi')\n",
+			want: "#!/usr/bin/python3\nprint('hi')\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			if tt.body != "" {
+				body = []byte(tt.body)
+			}
+			script := tt.script
+			if script == "" {
+				script = "postinst"
+			}
+
+			got, err := maintainerScript(script, body, tt.snippet)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("maintainerScript() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if got, _ := maintainerScript("postinst", nil, ""); got != nil {
+		t.Errorf("expected no script, got %q", got)
+	}
+}
+
+// TestMaintainerScript_PostinstOrder runs a generated postinst, checking
+// that the package's own setup runs before the units are started, even
+// if it exits early, and that a failing script stops the snippet.
+func TestMaintainerScript_PostinstOrder(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		want     string
+		wantFail bool
+	}{
+		{name: "ok", body: "#!/bin/sh\nset -e\necho setup\n", want: "setup\nstart configure\n"},
+		{name: "exit_early", body: "#!/bin/sh\necho setup\nexit 0\necho unreachable\n", want: "setup\nstart configure\n"},
+		{name: "fails", body: "#!/bin/sh\nset -e\necho setup\nfalse\n", want: "setup\n", wantFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := maintainerScript("postinst", []byte(tt.body), "echo start \"$1\"\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fileName := filepath.Join(t.TempDir(), "postinst")
+			err = os.WriteFile(fileName, script, 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := exec.Command(sh, fileName, "configure").Output()
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("unexpected result %v running:\n%s", err, script)
+			}
+			if diff := cmp.Diff(tt.want, string(out)); diff != "" {
+				t.Errorf("postinst output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Algorithm is a hash algorithm used to checksum repository files.
+type Algorithm string
+
+const (
+	MD5    Algorithm = "md5"
+	SHA1   Algorithm = "sha1"
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+)
+
+// All is every supported algorithm, weakest first.
+var All = []Algorithm{MD5, SHA1, SHA256, SHA512}
+
+// Parse parses a hash algorithm such as "sha256".
+func Parse(s string) (Algorithm, error) {
+	a := Algorithm(strings.ReplaceAll(strings.ToLower(s), "-", ""))
+	switch a {
+	case MD5, SHA1, SHA256, SHA512:
+		return a, nil
+	}
+	return "", fmt.Errorf("unsupported hash algorithm %q (expected one of md5, sha1, sha256, sha512)", s)
+}
+
+// ParseList parses a list of hash algorithms, removing duplicates. A
+// non-empty list must include sha256 or sha512, as APT rejects repositories
+// which are only checksummed with the weak md5 and sha1 algorithms.
+func ParseList(values []string) ([]Algorithm, error) {
+	var algorithms []Algorithm
+	seen := map[Algorithm]bool{}
+
+	for _, v := range values {
+		a, err := Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		if seen[a] {
+			continue
+		}
+		seen[a] = true
+		algorithms = append(algorithms, a)
+	}
+
+	if len(algorithms) > 0 && !seen[SHA256] && !seen[SHA512] {
+		return nil, fmt.Errorf("at least one of sha256 or sha512 is required, as APT rejects repositories which only have md5 or sha1 checksums")
+	}
+
+	return algorithms, nil
+}
+
+// New returns a new hash.Hash for the algorithm.
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case MD5:
+		return md5.New()
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	case SHA512:
+		return sha512.New()
+	}
+	panic(fmt.Sprintf("unsupported hash algorithm %q", a))
+}
+
+// Sums holds hex-encoded checksums keyed by algorithm.
+type Sums map[Algorithm]string
+
+// Hasher computes checksums for several algorithms in a single pass
+// over the data written to it.
+type Hasher struct {
+	algorithms []Algorithm
+	hashes     []hash.Hash
+	w          io.Writer
+	size       int64
+}
+
+// NewHasher returns a Hasher computing checksums for the given algorithms.
+func NewHasher(algorithms ...Algorithm) *Hasher {
+	h := Hasher{algorithms: algorithms}
+
+	var writers []io.Writer
+	for _, a := range algorithms {
+		hh := a.New()
+		h.hashes = append(h.hashes, hh)
+		writers = append(writers, hh)
+	}
+	h.w = io.MultiWriter(writers...)
+
+	return &h
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	h.size += int64(n)
+	return n, err
+}
+
+// Size returns the number of bytes written to the Hasher.
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+// Sums returns the checksums of the data written so far.
+func (h *Hasher) Sums() Sums {
+	sums := Sums{}
+	for i, a := range h.algorithms {
+		sums[a] = fmt.Sprintf("%x", h.hashes[i].Sum(nil))
+	}
+	return sums
+}
+
+// File computes the checksums of the file at path, returning the
+// checksums and the size of the file.
+func File(path string, algorithms ...Algorithm) (Sums, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error opening file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := NewHasher(algorithms...)
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, 0, err
+	}
+
+	return h.Sums(), h.Size(), nil
+}
@@ -0,0 +1,151 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Format is a compression format used for repository index files.
+type Format string
+
+const (
+	None  Format = "none"
+	Gzip  Format = "gz"
+	XZ    Format = "xz"
+	Zstd  Format = "zst"
+	Bzip2 Format = "bz2"
+)
+
+// All is every supported format, in the order that clients
+// are expected to prefer them when reading an index.
+var All = []Format{XZ, Zstd, Gzip, Bzip2, None}
+
+// Parse parses a compression format such as "xz" or "none".
+func Parse(s string) (Format, error) {
+	f := Format(strings.TrimPrefix(strings.ToLower(s), "."))
+	switch f {
+	case None, Gzip, XZ, Zstd, Bzip2:
+		return f, nil
+	case "", "uncompressed":
+		return None, nil
+	case "gzip":
+		return Gzip, nil
+	case "zstd":
+		return Zstd, nil
+	case "bzip2":
+		return Bzip2, nil
+	}
+	return "", fmt.Errorf("unsupported compression format %q (expected one of none, gz, xz, zst, bz2)", s)
+}
+
+// ParseList parses a list of compression formats, removing duplicates.
+func ParseList(values []string) ([]Format, error) {
+	var formats []Format
+	seen := map[Format]bool{}
+
+	for _, v := range values {
+		f, err := Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		formats = append(formats, f)
+	}
+
+	return formats, nil
+}
+
+// Ext returns the file extension for the format, including the leading dot.
+// The extension for None is empty.
+func (f Format) Ext() string {
+	if f == None {
+		return ""
+	}
+	return "." + string(f)
+}
+
+// NewWriter returns a writer which compresses data written to it into w.
+// The returned writer must be closed to flush any buffered data.
+func (f Format) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch f {
+	case None:
+		return nopCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case XZ:
+		return xz.NewWriter(w)
+	case Zstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	case Bzip2:
+		return bzip2.NewWriter(w, &bzip2.WriterConfig{Level: bzip2.BestCompression})
+	}
+	return nil, fmt.Errorf("unsupported compression format %q", f)
+}
+
+// NewReader returns a reader which decompresses data read from r.
+func (f Format) NewReader(r io.Reader) (io.ReadCloser, error) {
+	switch f {
+	case None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case XZ:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case Bzip2:
+		return bzip2.NewReader(r, nil)
+	}
+	return nil, fmt.Errorf("unsupported compression format %q", f)
+}
+
+// FromExt returns the format matching a file name's extension,
+// returning None if the extension is not recognised.
+func FromExt(name string) Format {
+	for _, f := range All {
+		if f != None && strings.HasSuffix(name, f.Ext()) {
+			return f
+		}
+	}
+	return None
+}
+
+// Detect returns the format of compressed data from its leading magic
+// bytes, returning None if the data is not compressed in a known format.
+func Detect(magic []byte) Format {
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return Gzip
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return XZ
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return Zstd
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return Bzip2
+	}
+	return None
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
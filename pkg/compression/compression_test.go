@@ -0,0 +1,70 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	input := []byte("Package: granted\nVersion: 0.27.5\nArchitecture: amd64\n\n")
+
+	for _, f := range All {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w, err := f.NewWriter(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(input); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := f.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, input) {
+				t.Errorf("round trip mismatch: got %q, want %q", got, input)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr bool
+	}{
+		{input: "none", want: None},
+		{input: "gz", want: Gzip},
+		{input: ".xz", want: XZ},
+		{input: "zstd", want: Zstd},
+		{input: "BZ2", want: Bzip2},
+		{input: "lzma", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
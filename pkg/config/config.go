@@ -0,0 +1,276 @@
+// Package config loads the linuxpack.yaml file, which describes
+// a repository so that it doesn't need to be configured with
+// command line flags on every run.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/lint"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file loaded if no other file is specified.
+const DefaultFile = "linuxpack.yaml"
+
+// Config describes a repository.
+type Config struct {
+	Vendor      string `yaml:"vendor"`
+	Licence     string `yaml:"licence"`
+	Description string `yaml:"description"`
+	// Origin and Label are the fields of the APT Release file.
+	Origin string `yaml:"origin"`
+	Label  string `yaml:"label"`
+	// Version is the Version field of the APT Release file.
+	Version string `yaml:"version"`
+	// Out is the directory that the repository is written to.
+	Out string `yaml:"out"`
+
+	Storage  Storage   `yaml:"storage"`
+	Channels []Channel `yaml:"channels"`
+	// Components are the components of the APT repository. New
+	// packages are added to the first component unless another is
+	// specified with --component.
+	Components []string `yaml:"components"`
+	// Architectures are the architectures of the APT repository.
+	Architectures []string  `yaml:"architectures"`
+	Signing       Signing   `yaml:"signing"`
+	Retention     Retention `yaml:"retention"`
+	// Defaults are applied to packages which don't set the fields.
+	Defaults Defaults `yaml:"defaults"`
+	// Overrides is the path to an override file, which sets
+	// the metadata of packages in the APT indexes by name.
+	Overrides string `yaml:"overrides"`
+	// Compression are the formats to write APT indexes in, such as gz or xz.
+	Compression []string `yaml:"compression"`
+	// Hashes are the hash algorithms to checksum packages and indexes with.
+	Hashes       []string `yaml:"hashes"`
+	Contents     *bool    `yaml:"contents"`
+	Translations *bool    `yaml:"translations"`
+	CDN          CDN      `yaml:"cdn"`
+	// Lint sets the severity of lint rules, such as maintainer: error.
+	// The severity is error, warning or off.
+	Lint map[string]string `yaml:"lint"`
+}
+
+// Storage configures where the published repository is stored.
+type Storage struct {
+	// Type is the type of storage: s3, gcs, azure or local.
+	Type string `yaml:"type"`
+	// Bucket is the S3 or GCS bucket.
+	Bucket string `yaml:"bucket"`
+	Region string `yaml:"region"`
+	// Endpoint is the URL of an S3-compatible service such as MinIO,
+	// or of a GCS or Azure emulator.
+	Endpoint string `yaml:"endpoint"`
+	// PathStyle addresses the bucket in the URL path rather than the
+	// host name, which most S3-compatible services require.
+	PathStyle bool `yaml:"path_style"`
+	// Profile is the AWS profile to load credentials from.
+	Profile string `yaml:"profile"`
+	// Account and Container are the Azure storage account and
+	// the blob container holding the repository.
+	Account   string `yaml:"account"`
+	Container string `yaml:"container"`
+	// Dir is the directory holding the repository, for local storage.
+	Dir string `yaml:"dir"`
+}
+
+// Channel is a release channel, such as stable or nightly,
+// which is published as a separate APT suite.
+type Channel struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// ValidFor is how long the Release file is valid for, such as 168h.
+	ValidFor             time.Duration `yaml:"valid_for"`
+	NotAutomatic         bool          `yaml:"not_automatic"`
+	ButAutomaticUpgrades bool          `yaml:"but_automatic_upgrades"`
+}
+
+// Signing configures the keys used to sign the repository metadata.
+type Signing struct {
+	// GPGKey is the ID of the GPG key to sign APT, RPM and pacman metadata with.
+	GPGKey string `yaml:"gpg_key"`
+	// SignedBy are the fingerprints of the keys the APT repository is signed by.
+	SignedBy []string `yaml:"signed_by"`
+	// APKKey is the path to the RSA private key to sign Alpine indexes with.
+	APKKey     string `yaml:"apk_key"`
+	APKKeyName string `yaml:"apk_key_name"`
+}
+
+// Defaults are the APT control fields of packages
+// which are used if a package doesn't set them.
+type Defaults struct {
+	Section  string `yaml:"section"`
+	Priority string `yaml:"priority"`
+}
+
+// priorities are the priorities defined by Debian policy.
+var priorities = []string{"required", "important", "standard", "optional", "extra"}
+
+// Validate returns an error if the priority isn't
+// defined by Debian policy or the section is invalid.
+func (d Defaults) Validate() error {
+	if d.Priority != "" && !slices.Contains(priorities, d.Priority) {
+		return fmt.Errorf("invalid priority %q (expected one of %s)", d.Priority, strings.Join(priorities, ", "))
+	}
+	if strings.ContainsAny(d.Section, " \n") {
+		return fmt.Errorf("invalid section %q", d.Section)
+	}
+	return nil
+}
+
+// Retention configures how many versions of each package are kept.
+type Retention struct {
+	// KeepVersions is the number of versions of each package kept in
+	// the APT Packages indexes. If zero, every version is kept.
+	KeepVersions int `yaml:"keep_versions"`
+}
+
+// CDN configures the CDN serving the repository.
+type CDN struct {
+	// CloudFrontDistributionID is the ID of the CloudFront distribution
+	// in front of the bucket. linuxpack doesn't upload the output, so it
+	// only prints the aws cloudfront create-invalidation command to run
+	// after uploading, rather than invalidating the paths itself.
+	CloudFrontDistributionID string `yaml:"cloudfront_distribution_id"`
+}
+
+// Load loads a config file. If the file does not exist
+// and optional is true, an empty config is returned.
+func Load(fileName string, optional bool) (Config, error) {
+	f, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) && optional {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+
+	c, err := Parse(f)
+	if err != nil {
+		return Config{}, fmt.Errorf("error loading %s: %w", fileName, err)
+	}
+	return c, nil
+}
+
+// Parse parses and validates a config file. Unknown fields are rejected.
+func Parse(r io.Reader) (Config, error) {
+	var c Config
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	err := dec.Decode(&c)
+	if err != nil && err != io.EOF {
+		return Config{}, err
+	}
+
+	return c, c.Validate()
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	switch c.Storage.Type {
+	case "", "s3":
+		if c.Storage.Dir != "" {
+			return errors.New("storage: dir can only be used with the local type")
+		}
+	case "gcs":
+		if c.Storage.Dir != "" {
+			return errors.New("storage: dir can only be used with the local type")
+		}
+	case "azure":
+		if c.Storage.Account == "" || c.Storage.Container == "" {
+			return errors.New("storage: account and container are required for the azure type")
+		}
+	case "local":
+		if c.Storage.Dir == "" {
+			return errors.New("storage: dir is required for the local type")
+		}
+		if c.Storage.Bucket != "" {
+			return errors.New("storage: bucket can't be used with the local type")
+		}
+	default:
+		return fmt.Errorf("storage: unsupported type %q (expected s3, gcs, azure or local)", c.Storage.Type)
+	}
+
+	seen := map[string]bool{}
+	for i, ch := range c.Channels {
+		if ch.Name == "" {
+			return fmt.Errorf("channels[%d]: name is required", i)
+		}
+		if seen[ch.Name] {
+			return fmt.Errorf("channels[%d]: duplicate channel %q", i, ch.Name)
+		}
+		seen[ch.Name] = true
+
+		if ch.ButAutomaticUpgrades && !ch.NotAutomatic {
+			return fmt.Errorf("channels[%d]: but_automatic_upgrades requires not_automatic", i)
+		}
+		if ch.ValidFor < 0 {
+			return fmt.Errorf("channels[%d]: valid_for must not be negative", i)
+		}
+	}
+
+	for i, component := range c.Components {
+		if component == "" || strings.ContainsAny(component, "/ ") {
+			return fmt.Errorf("components[%d]: invalid component %q", i, component)
+		}
+	}
+
+	for i, arch := range c.Architectures {
+		if arch == "" || arch == "all" || strings.ContainsAny(arch, "/ ") {
+			return fmt.Errorf("architectures[%d]: invalid architecture %q", i, arch)
+		}
+	}
+
+	if err := c.Defaults.Validate(); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+
+	if c.Retention.KeepVersions < 0 {
+		return errors.New("retention: keep_versions must not be negative")
+	}
+
+	if _, err := compression.ParseList(c.Compression); err != nil {
+		return fmt.Errorf("compression: %w", err)
+	}
+
+	if _, err := checksum.ParseList(c.Hashes); err != nil {
+		return fmt.Errorf("hashes: %w", err)
+	}
+
+	if _, err := lint.ParseConfig(c.Lint); err != nil {
+		return fmt.Errorf("lint: %w", err)
+	}
+
+	return nil
+}
+
+// Channel returns the config for the named channel. If the config
+// lists channels, the name must be one of them.
+func (c Config) Channel(name string) (Channel, error) {
+	if len(c.Channels) == 0 {
+		return Channel{Name: name}, nil
+	}
+
+	var names []string
+	for _, ch := range c.Channels {
+		if ch.Name == name {
+			return ch, nil
+		}
+		names = append(names, ch.Name)
+	}
+
+	return Channel{}, fmt.Errorf("channel %q is not configured (expected one of %s)", name, strings.Join(names, ", "))
+}
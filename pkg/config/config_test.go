@@ -0,0 +1,182 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		give    string
+		want    Config
+		wantErr string
+	}{
+		{
+			name: "ok",
+			give: `vendor: Common Fate
+origin: Common Fate APT Repository
+label: Common Fate
+version: "2.0"
+storage:
+  type: s3
+  bucket: example-bucket
+channels:
+  - name: stable
+  - name: nightly
+    valid_for: 168h
+    not_automatic: true
+    but_automatic_upgrades: true
+components: [main]
+architectures: [amd64, arm64]
+retention:
+  keep_versions: 5
+compression: [gz, xz]
+contents: false
+signing:
+  signed_by: [ABCD1234]
+`,
+			want: Config{
+				Vendor:  "Common Fate",
+				Origin:  "Common Fate APT Repository",
+				Label:   "Common Fate",
+				Version: "2.0",
+				Storage: Storage{Type: "s3", Bucket: "example-bucket"},
+				Channels: []Channel{
+					{Name: "stable"},
+					{Name: "nightly", ValidFor: 168 * time.Hour, NotAutomatic: true, ButAutomaticUpgrades: true},
+				},
+				Components:    []string{"main"},
+				Architectures: []string{"amd64", "arm64"},
+				Retention:     Retention{KeepVersions: 5},
+				Compression:   []string{"gz", "xz"},
+				Contents:      new(bool),
+				Signing:       Signing{SignedBy: []string{"ABCD1234"}},
+			},
+		},
+		{
+			name: "empty",
+			give: "",
+			want: Config{},
+		},
+		{
+			name:    "unknown_field",
+			give:    "bukket: example-bucket\n",
+			wantErr: "field bukket not found",
+		},
+		{
+			name:    "unsupported_storage",
+			give:    "storage:\n  type: ftp\n",
+			wantErr: `storage: unsupported type "ftp"`,
+		},
+		{
+			name: "s3_compatible_storage",
+			give: "storage:\n  bucket: packages\n  endpoint: http://localhost:9000\n  path_style: true\n  profile: minio\n",
+			want: Config{Storage: Storage{Bucket: "packages", Endpoint: "http://localhost:9000", PathStyle: true, Profile: "minio"}},
+		},
+		{
+			name: "defaults",
+			give: "defaults:\n  section: utils\n  priority: optional\n",
+			want: Config{Defaults: Defaults{Section: "utils", Priority: "optional"}},
+		},
+		{
+			name:    "invalid_priority",
+			give:    "defaults:\n  priority: low\n",
+			wantErr: `defaults: invalid priority "low"`,
+		},
+		{
+			name: "lint",
+			give: "lint:\n  maintainer: error\n",
+			want: Config{Lint: map[string]string{"maintainer": "error"}},
+		},
+		{
+			name:    "unknown_lint_rule",
+			give:    "lint:\n  description: off\n",
+			wantErr: `lint: unknown lint rule "description"`,
+		},
+		{
+			name: "azure_storage",
+			give: "storage:\n  type: azure\n  account: example\n  container: packages\n",
+			want: Config{Storage: Storage{Type: "azure", Account: "example", Container: "packages"}},
+		},
+		{
+			name: "local_storage",
+			give: "storage:\n  type: local\n  dir: /srv/apt\n",
+			want: Config{Storage: Storage{Type: "local", Dir: "/srv/apt"}},
+		},
+		{
+			name:    "local_storage_without_dir",
+			give:    "storage:\n  type: local\n",
+			wantErr: "storage: dir is required for the local type",
+		},
+		{
+			name:    "duplicate_channel",
+			give:    "channels:\n  - name: stable\n  - name: stable\n",
+			wantErr: `channels[1]: duplicate channel "stable"`,
+		},
+		{
+			name:    "but_automatic_upgrades",
+			give:    "channels:\n  - name: stable\n    but_automatic_upgrades: true\n",
+			wantErr: "channels[0]: but_automatic_upgrades requires not_automatic",
+		},
+		{
+			name:    "invalid_compression",
+			give:    "compression: [lz4]\n",
+			wantErr: `compression: unsupported compression format "lz4"`,
+		},
+		{
+			name:    "weak_hashes",
+			give:    "hashes: [md5, sha1]\n",
+			wantErr: "hashes: at least one of sha256 or sha512 is required, as APT rejects repositories which only have md5 or sha1 checksums",
+		},
+		{
+			name:    "all_architecture",
+			give:    "architectures: [all]\n",
+			wantErr: `architectures[0]: invalid architecture "all"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.give))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfig_Channel(t *testing.T) {
+	c := Config{Channels: []Channel{{Name: "stable"}, {Name: "nightly", NotAutomatic: true}}}
+
+	got, err := c.Channel("nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.NotAutomatic {
+		t.Errorf("expected the nightly channel config, got %+v", got)
+	}
+
+	_, err = c.Channel("beta")
+	if err == nil || err.Error() != `channel "beta" is not configured (expected one of stable, nightly)` {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// any channel is allowed if none are configured
+	got, err = Config{}.Channel("beta")
+	if err != nil || got.Name != "beta" {
+		t.Errorf("unexpected result %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,142 @@
+package contents
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Contents is a Contents-<arch> index, mapping file paths
+// to the packages which contain them.
+type Contents struct {
+	// Files maps a file path to the set of qualified package names
+	// ([section/]package) which provide it.
+	Files map[string]map[string]bool
+}
+
+// Add records that the package at location provides each of the files.
+// location is the qualified package name, such as "utils/granted".
+func (c *Contents) Add(location string, files []string) {
+	if c.Files == nil {
+		c.Files = map[string]map[string]bool{}
+	}
+
+	for _, f := range files {
+		if c.Files[f] == nil {
+			c.Files[f] = map[string]bool{}
+		}
+		c.Files[f][location] = true
+	}
+}
+
+// Remove removes every location of a package, whichever section it is
+// in. Paths which no other package provides are removed.
+func (c *Contents) Remove(pkg string) {
+	for path, locations := range c.Files {
+		for l := range locations {
+			if locationPackage(l) == pkg {
+				delete(locations, l)
+			}
+		}
+		if len(locations) == 0 {
+			delete(c.Files, path)
+		}
+	}
+}
+
+// Packages returns the sorted names of the packages in the index.
+func (c *Contents) Packages() []string {
+	var names []string
+	for _, locations := range c.Files {
+		for l := range locations {
+			if name := locationPackage(l); !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Location returns the qualified package name used in Contents indexes.
+func Location(section, pkg string) string {
+	if section == "" {
+		return pkg
+	}
+	return section + "/" + pkg
+}
+
+// locationPackage returns the package name of a location.
+func locationPackage(location string) string {
+	return location[strings.LastIndex(location, "/")+1:]
+}
+
+// Write writes the index sorted by file path.
+func (c *Contents) Write(w io.Writer) error {
+	var paths []string
+	for p := range c.Files {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+
+	for _, p := range paths {
+		var locations []string
+		for l := range c.Files[p] {
+			locations = append(locations, l)
+		}
+		slices.Sort(locations)
+
+		_, err := fmt.Fprintf(w, "%s %s\n", p, strings.Join(locations, ","))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read reads a Contents index.
+func Read(r io.Reader) (Contents, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var c Contents
+
+	var lineNum int
+	for sc.Scan() {
+		lineNum++
+		line := strings.TrimRight(sc.Text(), " \t")
+
+		if line == "" {
+			continue
+		}
+
+		// the file path may contain spaces, so the location
+		// is taken from the last whitespace-separated column
+		i := strings.LastIndexAny(line, " \t")
+		if i == -1 {
+			return Contents{}, fmt.Errorf("invalid line %v: expected a file path and location: %q", lineNum, line)
+		}
+
+		path := strings.TrimRight(line[:i], " \t")
+		locations := strings.Split(line[i+1:], ",")
+
+		// older indexes begin with a free-form header ending in "FILE LOCATION"
+		if path == "FILE" && locations[0] == "LOCATION" {
+			c = Contents{}
+			continue
+		}
+
+		for _, l := range locations {
+			c.Add(l, []string{path})
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return Contents{}, err
+	}
+
+	return c, nil
+}
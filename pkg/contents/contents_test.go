@@ -0,0 +1,104 @@
+package contents
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Contents
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			input: `usr/bin/assume utils/granted
+usr/bin/granted utils/granted,utils/granted-beta
+`,
+			want: Contents{
+				Files: map[string]map[string]bool{
+					"usr/bin/assume":  {"utils/granted": true},
+					"usr/bin/granted": {"utils/granted": true, "utils/granted-beta": true},
+				},
+			},
+		},
+		{
+			name: "legacy_header",
+			input: `This file maps each file available in the Debian
+system to the package from which it originates.
+
+FILE                                                    LOCATION
+usr/bin/granted                                         utils/granted
+`,
+			want: Contents{
+				Files: map[string]map[string]bool{
+					"usr/bin/granted": {"utils/granted": true},
+				},
+			},
+		},
+		{
+			name:    "missing_location",
+			input:   "usr/bin/granted\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var c Contents
+	c.Add("utils/granted", []string{"usr/bin/granted", "usr/bin/assume"})
+	c.Add("granted-beta", []string{"usr/bin/granted"})
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `usr/bin/assume utils/granted
+usr/bin/granted granted-beta,utils/granted
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Write() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	var c Contents
+	c.Add("utils/granted", []string{"usr/bin/granted", "usr/bin/assume"})
+	c.Add("granted-beta", []string{"usr/bin/granted"})
+	// the package may have moved section
+	c.Add("admin/granted", []string{"usr/bin/granted-admin"})
+
+	if diff := cmp.Diff([]string{"granted", "granted-beta"}, c.Packages()); diff != "" {
+		t.Errorf("Packages() mismatch (-want +got):\n%s", diff)
+	}
+
+	c.Remove("granted")
+
+	want := Contents{
+		Files: map[string]map[string]bool{
+			"usr/bin/granted": {"granted-beta": true},
+		},
+	}
+	if diff := cmp.Diff(want, c); diff != "" {
+		t.Errorf("Remove() mismatch (-want +got):\n%s", diff)
+	}
+}
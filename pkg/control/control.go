@@ -15,6 +15,14 @@ type Control struct {
 	Architecture  string
 	Maintainer    string
 	InstalledSize string
+	PreDepends    string
+	Depends       string
+	Recommends    string
+	Suggests      string
+	Conflicts     string
+	Breaks        string
+	Replaces      string
+	Provides      string
 	Homepage      string
 	Description   string
 }
@@ -24,14 +32,35 @@ func Parse(r io.Reader) (Control, error) {
 
 	sc := bufio.NewScanner(r)
 
+	var lastKey string
 	for sc.Scan() {
 		line := sc.Text()
+
+		// lines beginning with whitespace continue the previous field,
+		// such as the long description
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if lastKey == "" {
+				return Control{}, fmt.Errorf("invalid line: continuation line without a field: %s", line)
+			}
+			values[lastKey] += "\n" + line
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+
 		before, after, found := strings.Cut(line, ": ")
 		if !found {
 			return Control{}, fmt.Errorf("invalid line: did not contain a \": \" separator: %s", line)
 		}
 
 		values[before] = after
+		lastKey = before
+	}
+
+	if err := sc.Err(); err != nil {
+		return Control{}, err
 	}
 
 	res := Control{
@@ -42,9 +71,77 @@ func Parse(r io.Reader) (Control, error) {
 		Architecture:  values["Architecture"],
 		Maintainer:    values["Maintainer"],
 		InstalledSize: values["Installed-Size"],
+		PreDepends:    values["Pre-Depends"],
+		Depends:       values["Depends"],
+		Recommends:    values["Recommends"],
+		Suggests:      values["Suggests"],
+		Conflicts:     values["Conflicts"],
+		Breaks:        values["Breaks"],
+		Replaces:      values["Replaces"],
+		Provides:      values["Provides"],
 		Homepage:      values["Homepage"],
 		Description:   values["Description"],
 	}
 
 	return res, nil
 }
+
+// Write writes the control file, omitting empty fields. The Description
+// is written as-is, so lines of the long description must already be
+// indented by a space.
+func (c Control) Write(w io.Writer) error {
+	fields := []struct {
+		key   string
+		value string
+	}{
+		{"Package", c.Package},
+		{"Version", c.Version},
+		{"Architecture", c.Architecture},
+		{"Maintainer", c.Maintainer},
+		{"Installed-Size", c.InstalledSize},
+		{"Pre-Depends", c.PreDepends},
+		{"Depends", c.Depends},
+		{"Recommends", c.Recommends},
+		{"Suggests", c.Suggests},
+		{"Conflicts", c.Conflicts},
+		{"Breaks", c.Breaks},
+		{"Replaces", c.Replaces},
+		{"Provides", c.Provides},
+		{"Section", c.Section},
+		{"Priority", c.Priority},
+		{"Homepage", c.Homepage},
+		{"Description", c.Description},
+	}
+
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", f.key, f.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FormatDescription formats a synopsis and a multi-line long description
+// as a Description field value, indenting the long description and
+// replacing blank lines with " ." as required by deb-control(5).
+func FormatDescription(synopsis string, long string) string {
+	long = strings.TrimSpace(long)
+	if long == "" {
+		return synopsis
+	}
+
+	var b strings.Builder
+	b.WriteString(synopsis)
+	for _, line := range strings.Split(long, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			line = "."
+		}
+		b.WriteString("\n " + line)
+	}
+	return b.String()
+}
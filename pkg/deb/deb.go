@@ -0,0 +1,211 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/compression"
+)
+
+const arMagic = "!<arch>\n"
+
+// Deb is the metadata read from a .deb package.
+type Deb struct {
+	// Control is the contents of the control file in control.tar.
+	Control []byte
+	// Files are the paths of the regular files and symlinks in data.tar,
+	// relative to the filesystem root and without a leading slash.
+	Files []string
+	// DataSize is the total size in bytes of the regular files in data.tar.
+	DataSize int64
+}
+
+// Read reads a .deb package from r. The package is read in a single
+// pass without buffering the data archive in memory.
+func Read(r io.Reader) (*Deb, error) {
+	return read(r, false)
+}
+
+// ReadControl reads the control file of a .deb package from r, without
+// reading the data archive which follows it.
+func ReadControl(r io.Reader) ([]byte, error) {
+	d, err := read(r, true)
+	if err != nil {
+		return nil, err
+	}
+	return d.Control, nil
+}
+
+// read reads a .deb package, stopping after the control file if
+// controlOnly is true.
+func read(r io.Reader, controlOnly bool) (*Deb, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("error reading ar header: %w", err)
+	}
+	if string(magic) != arMagic {
+		return nil, errors.New("not a .deb package: missing ar magic")
+	}
+
+	var d Deb
+	var foundControl, foundData bool
+
+	for {
+		name, size, err := readARHeader(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		member := io.LimitReader(br, size)
+
+		switch {
+		case strings.HasPrefix(name, "control.tar"):
+			d.Control, err = readControl(name, member)
+			if err != nil {
+				return nil, err
+			}
+			if controlOnly {
+				return &d, nil
+			}
+			foundControl = true
+		case strings.HasPrefix(name, "data.tar"):
+			d.Files, d.DataSize, err = readFiles(name, member)
+			if err != nil {
+				return nil, err
+			}
+			foundData = true
+		}
+
+		// skip any unread data in the member, plus the padding byte
+		// which aligns members to an even offset
+		if _, err := io.Copy(io.Discard, member); err != nil {
+			return nil, err
+		}
+		if size%2 == 1 {
+			if _, err := br.Discard(1); err != nil && err != io.EOF {
+				return nil, err
+			}
+		}
+	}
+
+	if !foundControl {
+		return nil, errors.New("invalid .deb package: no control.tar member")
+	}
+	if !foundData {
+		return nil, errors.New("invalid .deb package: no data.tar member")
+	}
+
+	return &d, nil
+}
+
+// readARHeader reads the name and size of the next ar member.
+func readARHeader(r io.Reader) (string, int64, error) {
+	header := make([]byte, 60)
+	n, err := io.ReadFull(r, header)
+	if n == 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+		return "", 0, io.EOF
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading ar member header: %w", err)
+	}
+
+	if string(header[58:60]) != "`\n" {
+		return "", 0, errors.New("invalid ar member header")
+	}
+
+	// GNU ar terminates names with a slash
+	name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid ar member size for %s: %w", name, err)
+	}
+
+	return name, size, nil
+}
+
+// openTar returns a tar reader for an ar member, decompressing it
+// according to the member's file extension.
+func openTar(name string, r io.Reader) (*tar.Reader, io.Closer, error) {
+	format := compression.FromExt(name)
+	if strings.HasSuffix(name, ".zstd") {
+		format = compression.Zstd
+	}
+
+	dr, err := format.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decompressing %s: %w", name, err)
+	}
+
+	return tar.NewReader(dr), dr, nil
+}
+
+func readControl(name string, r io.Reader) ([]byte, error) {
+	tr, closer, err := openTar(name, r)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s does not contain a control file", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+
+		if path.Clean(hdr.Name) != "control" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// readFiles returns the paths of the files in data.tar and their total size.
+func readFiles(name string, r io.Reader) ([]string, int64, error) {
+	tr, closer, err := openTar(name, r)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer closer.Close()
+
+	var files []string
+	var size int64
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, size, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading %s: %w", name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+			files = append(files, strings.TrimPrefix(path.Clean("/"+hdr.Name), "/"))
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			size += hdr.Size
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/control"
+)
+
+// Archive is the contents of a .deb package to be written.
+type Archive struct {
+	// Control is the package's control file. The Installed-Size
+	// field is calculated from the files in the package.
+	Control control.Control
+	// Conffiles are the absolute paths of configuration files,
+	// which dpkg preserves local changes to when upgrading.
+	Conffiles []string
+	// Scripts maps a maintainer script name, such as postinst, to its contents.
+	Scripts map[string][]byte
+	Files   []File
+	// ModTime is the modification time of every file in the package.
+	ModTime time.Time
+}
+
+// File is a file installed by a .deb package.
+type File struct {
+	// Name is the absolute path that the file is installed to.
+	Name string
+	Mode fs.FileMode
+	// Source is the path of the file on disk to copy into the package.
+	// If empty, Data is used as the contents of the file.
+	Source string
+	Data   []byte
+	// LinkTarget is the target of the file if it is a symlink.
+	LinkTarget string
+	// Dir is true if the file is an empty directory.
+	Dir bool
+}
+
+// Write writes the archive as a .deb package to w.
+//
+// The data archive is written to a temporary file rather than memory,
+// as the size of each ar member must be known before it is written.
+func Write(w io.Writer, a Archive) error {
+	data, err := os.CreateTemp("", "linuxpack-data-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(data.Name())
+	defer data.Close()
+
+	sums, installedSize, err := writeData(data, a)
+	if err != nil {
+		return err
+	}
+
+	a.Control.InstalledSize = strconv.FormatInt((installedSize+1023)/1024, 10)
+
+	var ctrl bytes.Buffer
+	err = writeControl(&ctrl, a, sums)
+	if err != nil {
+		return err
+	}
+
+	dataSize, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = data.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, arMagic); err != nil {
+		return err
+	}
+
+	err = writeARMember(w, "debian-binary", a.ModTime, 4, strings.NewReader("2.0\n"))
+	if err != nil {
+		return err
+	}
+	err = writeARMember(w, "control.tar.gz", a.ModTime, int64(ctrl.Len()), &ctrl)
+	if err != nil {
+		return err
+	}
+	return writeARMember(w, "data.tar.gz", a.ModTime, dataSize, data)
+}
+
+// writeARMember writes an ar member header followed by its contents,
+// padding the member to an even offset.
+func writeARMember(w io.Writer, name string, modTime time.Time, size int64, r io.Reader) error {
+	_, err := fmt.Fprintf(w, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, modTime.Unix(), 0, 0, "100644", size)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("error writing %s: wrote %d bytes, expected %d", name, n, size)
+	}
+
+	if size%2 == 1 {
+		_, err = w.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// writeData writes the data.tar.gz member, returning the md5sums of
+// the regular files and the total size of the installed files.
+func writeData(w io.Writer, a Archive) ([]string, int64, error) {
+	gz, err := compression.Gzip.NewWriter(w)
+	if err != nil {
+		return nil, 0, err
+	}
+	tw := tar.NewWriter(gz)
+
+	files := slices.Clone(a.Files)
+	slices.SortFunc(files, func(a, b File) int { return strings.Compare(a.Name, b.Name) })
+
+	// dpkg requires the parent directories of every file to be
+	// included in the archive, so they are created implicitly
+	dirs := map[string]bool{}
+	for _, f := range files {
+		for dir := path.Dir(path.Clean(f.Name)); dir != "/"; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+		if f.Dir {
+			dirs[path.Clean(f.Name)] = true
+		}
+	}
+
+	var dirNames []string
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+	}
+	slices.Sort(dirNames)
+
+	err = tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755, ModTime: a.ModTime, Format: tar.FormatGNU})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, dir := range dirNames {
+		err = tw.WriteHeader(&tar.Header{
+			Name:     "." + dir + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+			ModTime:  a.ModTime,
+			Format:   tar.FormatGNU,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var sums []string
+	var installedSize int64
+
+	for _, f := range files {
+		if f.Dir {
+			continue
+		}
+
+		name := "." + path.Clean(f.Name)
+
+		if f.LinkTarget != "" {
+			err = tw.WriteHeader(&tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeSymlink,
+				Linkname: f.LinkTarget,
+				Mode:     0777,
+				ModTime:  a.ModTime,
+				Format:   tar.FormatGNU,
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		sum, size, err := writeFile(tw, name, f, a.ModTime)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sums = append(sums, sum+"  "+strings.TrimPrefix(name, "./"))
+		installedSize += size
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sums, installedSize, gz.Close()
+}
+
+// writeFile writes a regular file to the data archive, returning its md5sum and size.
+func writeFile(tw *tar.Writer, name string, f File, modTime time.Time) (string, int64, error) {
+	var r io.Reader = bytes.NewReader(f.Data)
+	size := int64(len(f.Data))
+
+	if f.Source != "" {
+		src, err := os.Open(f.Source)
+		if err != nil {
+			return "", 0, err
+		}
+		defer src.Close()
+
+		info, err := src.Stat()
+		if err != nil {
+			return "", 0, err
+		}
+		if !info.Mode().IsRegular() {
+			return "", 0, fmt.Errorf("%s is not a regular file", f.Source)
+		}
+
+		r = src
+		size = info.Size()
+	}
+
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     int64(f.Mode.Perm()),
+		Size:     size,
+		ModTime:  modTime,
+		Format:   tar.FormatGNU,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	h := md5.New()
+	if _, err := io.Copy(tw, io.TeeReader(r, h)); err != nil {
+		return "", 0, fmt.Errorf("error writing %s: %w", name, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// writeControl writes the control.tar.gz member.
+func writeControl(w io.Writer, a Archive, sums []string) error {
+	gz, err := compression.Gzip.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	err = tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755, ModTime: a.ModTime, Format: tar.FormatGNU})
+	if err != nil {
+		return err
+	}
+
+	var ctrl bytes.Buffer
+	err = a.Control.Write(&ctrl)
+	if err != nil {
+		return err
+	}
+
+	files := []File{{Name: "control", Mode: 0644, Data: ctrl.Bytes()}}
+
+	if len(sums) > 0 {
+		files = append(files, File{Name: "md5sums", Mode: 0644, Data: []byte(joinLines(sums))})
+	}
+
+	if len(a.Conffiles) > 0 {
+		files = append(files, File{Name: "conffiles", Mode: 0644, Data: []byte(joinLines(a.Conffiles))})
+	}
+
+	var scripts []string
+	for name := range a.Scripts {
+		scripts = append(scripts, name)
+	}
+	slices.Sort(scripts)
+
+	for _, name := range scripts {
+		files = append(files, File{Name: name, Mode: 0755, Data: a.Scripts[name]})
+	}
+
+	for _, f := range files {
+		_, _, err = writeFile(tw, "./"+f.Name, f, a.ModTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,72 @@
+package deb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWrite(t *testing.T) {
+	a := Archive{
+		Control: control.Control{
+			Package:      "granted",
+			Version:      "0.27.5",
+			Architecture: "amd64",
+			Maintainer:   "Common Fate <hello@commonfate.io>",
+			Depends:      "libc6",
+			Description:  control.FormatDescription("The easiest way to access your cloud.", "Granted is a CLI.\n\nSecond paragraph."),
+		},
+		Conffiles: []string{"/etc/granted/granted.conf"},
+		Scripts:   map[string][]byte{"postinst": []byte("#!/bin/sh\necho hi\n")},
+		Files: []File{
+			{Name: "/usr/bin/granted", Mode: 0755, Data: []byte("#!/bin/sh\n")},
+			{Name: "/etc/granted/granted.conf", Mode: 0644, Data: []byte("a=1\n")},
+			{Name: "/usr/bin/assume", LinkTarget: "/usr/bin/granted"},
+			{Name: "/var/lib/granted", Dir: true},
+		},
+		ModTime: time.Unix(1700000000, 0),
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ar archive must have an even length, as members are padded
+	if buf.Len()%2 != 0 {
+		t.Errorf("expected archive length to be even, got %d", buf.Len())
+	}
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Package: granted
+Version: 0.27.5
+Architecture: amd64
+Maintainer: Common Fate <hello@commonfate.io>
+Installed-Size: 1
+Depends: libc6
+Description: The easiest way to access your cloud.
+ Granted is a CLI.
+ .
+ Second paragraph.
+`
+	if diff := cmp.Diff(want, string(got.Control)); diff != "" {
+		t.Errorf("control mismatch (-want +got):\n%s", diff)
+	}
+
+	wantFiles := []string{"etc/granted/granted.conf", "usr/bin/assume", "usr/bin/granted"}
+	if diff := cmp.Diff(wantFiles, got.Files); diff != "" {
+		t.Errorf("files mismatch (-want +got):\n%s", diff)
+	}
+
+	// the regular files are "#!/bin/sh\n" and "a=1\n"
+	if got.DataSize != 14 {
+		t.Errorf("expected a data size of 14 bytes, got %d", got.DataSize)
+	}
+}
@@ -0,0 +1,343 @@
+// Package lint checks the metadata of .deb packages against Debian
+// policy, so that invalid packages are caught before they are published.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/control"
+)
+
+// Severity is how a rule's findings are treated.
+type Severity string
+
+const (
+	// Error findings stop the package from being published.
+	Error Severity = "error"
+	// Warning findings are reported but don't stop publishing.
+	Warning Severity = "warning"
+	// Off disables a rule.
+	Off Severity = "off"
+)
+
+// ParseSeverity parses a severity such as "warning".
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(s)); sev {
+	case Error, Warning, Off:
+		return sev, nil
+	}
+	return "", fmt.Errorf("invalid severity %q (expected error, warning or off)", s)
+}
+
+// Package is the metadata of a .deb package which is checked.
+type Package struct {
+	// FileName is the base name of the package file.
+	FileName string
+	Control  control.Control
+	// DataSize is the total size in bytes of the files in the package.
+	DataSize int64
+}
+
+// Rule is a check of package metadata.
+type Rule struct {
+	Name        string
+	Description string
+	// Severity is the severity of the rule's findings
+	// unless it is overridden by a Config.
+	Severity Severity
+	// check returns a message for each problem found.
+	check func(p Package) []string
+}
+
+// Rules are the rules packages are checked against.
+var Rules = []Rule{
+	{
+		Name:        "required-fields",
+		Description: "the Package, Version, Architecture, Maintainer and Description fields are set",
+		Severity:    Error,
+		check:       checkRequiredFields,
+	},
+	{
+		Name:        "package-name",
+		Description: "the package name follows Debian policy",
+		Severity:    Error,
+		check:       checkPackageName,
+	},
+	{
+		Name:        "version",
+		Description: "the version follows Debian policy",
+		Severity:    Error,
+		check:       checkVersion,
+	},
+	{
+		Name:        "maintainer",
+		Description: "the maintainer is a name and email address, such as Jane Doe <jane@example.com>",
+		Severity:    Warning,
+		check:       checkMaintainer,
+	},
+	{
+		Name:        "filename-architecture",
+		Description: "the architecture in the file name matches the Architecture field",
+		Severity:    Error,
+		check:       checkFilenameArchitecture,
+	},
+	{
+		Name:        "installed-size",
+		Description: "Installed-Size is a number of KiB at least the size of the packaged files",
+		Severity:    Warning,
+		check:       checkInstalledSize,
+	},
+}
+
+// Config overrides the severity of rules, keyed by rule name.
+type Config map[string]Severity
+
+// ParseConfig parses the severity of each rule, such as from the
+// lint section of the config file.
+func ParseConfig(values map[string]string) (Config, error) {
+	c := Config{}
+
+	for name, value := range values {
+		if !ruleExists(name) {
+			return nil, fmt.Errorf("unknown lint rule %q (expected one of %s)", name, strings.Join(ruleNames(), ", "))
+		}
+		sev, err := ParseSeverity(value)
+		if err != nil {
+			return nil, fmt.Errorf("lint rule %s: %w", name, err)
+		}
+		c[name] = sev
+	}
+
+	return c, nil
+}
+
+// Severity returns the severity of a rule.
+func (c Config) Severity(r Rule) Severity {
+	if sev, ok := c[r.Name]; ok {
+		return sev
+	}
+	return r.Severity
+}
+
+func ruleExists(name string) bool {
+	for _, r := range Rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleNames() []string {
+	var names []string
+	for _, r := range Rules {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Finding is a problem found in a package.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Severity, f.Message, f.Rule)
+}
+
+// Check checks a package against every rule which isn't turned off.
+func Check(p Package, c Config) []Finding {
+	var findings []Finding
+
+	for _, r := range Rules {
+		sev := c.Severity(r)
+		if sev == Off {
+			continue
+		}
+		for _, msg := range r.check(p) {
+			findings = append(findings, Finding{Rule: r.Name, Severity: sev, Message: msg})
+		}
+	}
+
+	return findings
+}
+
+// Errors returns the findings with the Error severity.
+func Errors(findings []Finding) []Finding {
+	var errs []Finding
+	for _, f := range findings {
+		if f.Severity == Error {
+			errs = append(errs, f)
+		}
+	}
+	return errs
+}
+
+func checkRequiredFields(p Package) []string {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"Package", p.Control.Package},
+		{"Version", p.Control.Version},
+		{"Architecture", p.Control.Architecture},
+		{"Maintainer", p.Control.Maintainer},
+		{"Description", p.Control.Description},
+	}
+
+	var msgs []string
+	for _, f := range fields {
+		if strings.TrimSpace(f.value) == "" {
+			msgs = append(msgs, fmt.Sprintf("the %s field is missing", f.name))
+		}
+	}
+	return msgs
+}
+
+// packageName is the syntax of package names in Debian policy 5.6.1.
+var packageName = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+
+func checkPackageName(p Package) []string {
+	// a missing name is reported by required-fields
+	if p.Control.Package == "" || packageName.MatchString(p.Control.Package) {
+		return nil
+	}
+	return []string{fmt.Sprintf("invalid package name %q: names must be at least two characters of lowercase letters, digits, +, - and ., starting with a letter or digit", p.Control.Package)}
+}
+
+var (
+	epochSyntax    = regexp.MustCompile(`^[0-9]+$`)
+	upstreamSyntax = regexp.MustCompile(`^[0-9][A-Za-z0-9.+~:-]*$`)
+	revisionSyntax = regexp.MustCompile(`^[A-Za-z0-9.+~]+$`)
+)
+
+// checkVersion checks the version syntax in Debian policy 5.6.12,
+// [epoch:]upstream_version[-debian_revision].
+func checkVersion(p Package) []string {
+	v := p.Control.Version
+	if v == "" {
+		return nil
+	}
+
+	invalid := func(reason string) []string {
+		return []string{fmt.Sprintf("invalid version %q: %s", v, reason)}
+	}
+
+	rest := v
+	if epoch, after, found := strings.Cut(v, ":"); found {
+		if !epochSyntax.MatchString(epoch) {
+			return invalid("the epoch before the colon must be a number")
+		}
+		rest = after
+	}
+
+	upstream := rest
+	if i := strings.LastIndex(rest, "-"); i >= 0 {
+		upstream = rest[:i]
+		if !revisionSyntax.MatchString(rest[i+1:]) {
+			return invalid("the revision after the last hyphen may only contain letters, digits, +, . and ~")
+		}
+	}
+
+	if upstream == "" || upstream[0] < '0' || upstream[0] > '9' {
+		return invalid("the upstream version must start with a digit")
+	}
+	if !upstreamSyntax.MatchString(upstream) {
+		return invalid("the upstream version may only contain letters, digits, ., +, ~, - and :")
+	}
+	return nil
+}
+
+// maintainerSyntax is a name followed by an email address in angle brackets.
+var maintainerSyntax = regexp.MustCompile(`^[^<>]*[^<>\s]\s*<[^<>\s@]+@[^<>\s@]+>$`)
+
+func checkMaintainer(p Package) []string {
+	if p.Control.Maintainer == "" || maintainerSyntax.MatchString(p.Control.Maintainer) {
+		return nil
+	}
+	return []string{fmt.Sprintf("maintainer %q is not in the form Name <email>", p.Control.Maintainer)}
+}
+
+// architectures are the Debian architecture names
+// which are recognised at the end of file names.
+var architectures = []string{
+	"all", "amd64", "arm64", "armel", "armhf", "i386",
+	"mips64el", "mipsel", "ppc64el", "riscv64", "s390x",
+}
+
+// architectureAliases map the architecture names used by build tools,
+// such as GoReleaser, to Debian architecture names.
+var architectureAliases = map[string]string{
+	"386":     "i386",
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"armv6":   "armel",
+	"armv7":   "armhf",
+	"ppc64le": "ppc64el",
+}
+
+// fileNameArchitecture returns the known architecture name at the end of
+// a file name, after an underscore. Names are matched as a whole suffix,
+// as some contain underscores, such as x86_64.
+func fileNameArchitecture(fileName string) (string, bool) {
+	base := strings.TrimSuffix(fileName, ".deb")
+
+	names := slices.Clone(architectures)
+	for alias := range architectureAliases {
+		names = append(names, alias)
+	}
+
+	var found string
+	for _, name := range names {
+		if strings.HasSuffix(base, "_"+name) && len(name) > len(found) {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// checkFilenameArchitecture checks the architecture at the end of a file
+// name such as granted_0.27.4_linux_amd64.deb. File names which don't
+// end with a known architecture aren't checked.
+func checkFilenameArchitecture(p Package) []string {
+	name, ok := fileNameArchitecture(p.FileName)
+	if !ok || p.Control.Architecture == "" {
+		return nil
+	}
+
+	arch := name
+	if alias, ok := architectureAliases[name]; ok {
+		arch = alias
+	}
+	if arch == p.Control.Architecture {
+		return nil
+	}
+	return []string{fmt.Sprintf("the file name %s is for architecture %q, but the package's Architecture is %q", p.FileName, name, p.Control.Architecture)}
+}
+
+func checkInstalledSize(p Package) []string {
+	if p.Control.InstalledSize == "" {
+		return []string{"the Installed-Size field is missing"}
+	}
+
+	kib, err := strconv.ParseInt(p.Control.InstalledSize, 10, 64)
+	if err != nil || kib < 0 {
+		return []string{fmt.Sprintf("invalid Installed-Size %q: expected a number of KiB", p.Control.InstalledSize)}
+	}
+
+	// Installed-Size is rounded up, and also counts directories
+	// and metadata, so it is only checked as a lower bound
+	dataKiB := (p.DataSize + 1023) / 1024
+	if kib < dataKiB {
+		return []string{fmt.Sprintf("Installed-Size is %d KiB, less than the %d KiB of files in the package", kib, dataKiB)}
+	}
+	return nil
+}
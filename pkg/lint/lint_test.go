@@ -0,0 +1,199 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/google/go-cmp/cmp"
+)
+
+// valid is a package which passes every rule.
+var valid = Package{
+	FileName: "granted_0.27.5_linux_amd64.deb",
+	Control: control.Control{
+		Package:       "granted",
+		Version:       "0.27.5",
+		Architecture:  "amd64",
+		Maintainer:    "Common Fate <hello@commonfate.io>",
+		InstalledSize: "2",
+		Description:   "The easiest way to access your cloud.",
+	},
+	DataSize: 2048,
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *Package)
+		config Config
+		want   []Finding
+	}{
+		{
+			name:   "valid",
+			modify: func(p *Package) {},
+		},
+		{
+			name:   "missing_fields",
+			modify: func(p *Package) { p.Control.Version = ""; p.Control.Maintainer = "" },
+			want: []Finding{
+				{Rule: "required-fields", Severity: Error, Message: "the Version field is missing"},
+				{Rule: "required-fields", Severity: Error, Message: "the Maintainer field is missing"},
+			},
+		},
+		{
+			name:   "invalid_name",
+			modify: func(p *Package) { p.Control.Package = "Granted_CLI" },
+			want: []Finding{
+				{Rule: "package-name", Severity: Error, Message: `invalid package name "Granted_CLI": names must be at least two characters of lowercase letters, digits, +, - and ., starting with a letter or digit`},
+			},
+		},
+		{
+			name:   "epoch_and_revision",
+			modify: func(p *Package) { p.Control.Version = "1:0.27.5-rc1-1ubuntu1~22.04" },
+		},
+		{
+			name:   "version_without_digit",
+			modify: func(p *Package) { p.Control.Version = "v0.27.5" },
+			want: []Finding{
+				{Rule: "version", Severity: Error, Message: `invalid version "v0.27.5": the upstream version must start with a digit`},
+			},
+		},
+		{
+			name:   "invalid_epoch",
+			modify: func(p *Package) { p.Control.Version = "a:0.27.5" },
+			want: []Finding{
+				{Rule: "version", Severity: Error, Message: `invalid version "a:0.27.5": the epoch before the colon must be a number`},
+			},
+		},
+		{
+			name:   "invalid_revision",
+			modify: func(p *Package) { p.Control.Version = "0.27.5-1_2" },
+			want: []Finding{
+				{Rule: "version", Severity: Error, Message: `invalid version "0.27.5-1_2": the revision after the last hyphen may only contain letters, digits, +, . and ~`},
+			},
+		},
+		{
+			name:   "invalid_upstream",
+			modify: func(p *Package) { p.Control.Version = "0.27 5" },
+			want: []Finding{
+				{Rule: "version", Severity: Error, Message: `invalid version "0.27 5": the upstream version may only contain letters, digits, ., +, ~, - and :`},
+			},
+		},
+		{
+			name:   "maintainer_without_email",
+			modify: func(p *Package) { p.Control.Maintainer = "Common Fate" },
+			want: []Finding{
+				{Rule: "maintainer", Severity: Warning, Message: `maintainer "Common Fate" is not in the form Name <email>`},
+			},
+		},
+		{
+			name:   "filename_architecture_mismatch",
+			modify: func(p *Package) { p.FileName = "granted_0.27.5_linux_arm64.deb" },
+			want: []Finding{
+				{Rule: "filename-architecture", Severity: Error, Message: `the file name granted_0.27.5_linux_arm64.deb is for architecture "arm64", but the package's Architecture is "amd64"`},
+			},
+		},
+		{
+			name: "filename_architecture_alias",
+			modify: func(p *Package) {
+				p.FileName = "granted_0.27.5_linux_386.deb"
+				p.Control.Architecture = "i386"
+			},
+		},
+		{
+			name:   "filename_architecture_x86_64",
+			modify: func(p *Package) { p.FileName = "granted_0.27.5_linux_x86_64.deb" },
+		},
+		{
+			name: "filename_architecture_x86_64_mismatch",
+			modify: func(p *Package) {
+				p.FileName = "granted_0.27.5_linux_x86_64.deb"
+				p.Control.Architecture = "arm64"
+			},
+			want: []Finding{
+				{Rule: "filename-architecture", Severity: Error, Message: `the file name granted_0.27.5_linux_x86_64.deb is for architecture "x86_64", but the package's Architecture is "arm64"`},
+			},
+		},
+		{
+			name:   "filename_without_architecture",
+			modify: func(p *Package) { p.FileName = "granted.deb" },
+		},
+		{
+			name:   "installed_size_too_small",
+			modify: func(p *Package) { p.Control.InstalledSize = "1" },
+			want: []Finding{
+				{Rule: "installed-size", Severity: Warning, Message: "Installed-Size is 1 KiB, less than the 2 KiB of files in the package"},
+			},
+		},
+		{
+			name:   "installed_size_invalid",
+			modify: func(p *Package) { p.Control.InstalledSize = "2MB" },
+			want: []Finding{
+				{Rule: "installed-size", Severity: Warning, Message: `invalid Installed-Size "2MB": expected a number of KiB`},
+			},
+		},
+		{
+			name:   "severity_overridden",
+			modify: func(p *Package) { p.Control.Maintainer = "Common Fate"; p.Control.InstalledSize = "" },
+			config: Config{"maintainer": Error, "installed-size": Off},
+			want: []Finding{
+				{Rule: "maintainer", Severity: Error, Message: `maintainer "Common Fate" is not in the form Name <email>`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.modify(&p)
+
+			got := Check(p, tt.config)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Check() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		give    map[string]string
+		want    Config
+		wantErr string
+	}{
+		{
+			name: "ok",
+			give: map[string]string{"maintainer": "Error", "installed-size": "off"},
+			want: Config{"maintainer": Error, "installed-size": Off},
+		},
+		{
+			name:    "unknown_rule",
+			give:    map[string]string{"description": "off"},
+			wantErr: `unknown lint rule "description" (expected one of filename-architecture, installed-size, maintainer, package-name, required-fields, version)`,
+		},
+		{
+			name:    "invalid_severity",
+			give:    map[string]string{"version": "fatal"},
+			wantErr: `lint rule version: invalid severity "fatal" (expected error, warning or off)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(tt.give)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ParseConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// Package output manages the output folder which repositories are written
+// to, so that a failed run never leaves a partially written repository and
+// directories which weren't created by linuxpack are never deleted.
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// MarkerFile is written to every output folder, marking
+// it as safe for linuxpack to delete and rewrite.
+const MarkerFile = ".linuxpack"
+
+// knownEntries are the top-level entries which linuxpack writes. Output
+// folders written before the marker file was introduced are recognised
+// by containing only these entries.
+var knownEntries = []string{MarkerFile, "dists", "pool", "rpm", "alpine", "archlinux", "snapshots"}
+
+// Check returns an error if dir exists and wasn't written by linuxpack,
+// so must not be deleted. A directory which doesn't exist or is empty
+// can be written.
+func Check(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Name() == MarkerFile {
+			return nil
+		}
+	}
+
+	for _, e := range entries {
+		if !slices.Contains(knownEntries, e.Name()) {
+			return fmt.Errorf("refusing to delete %s as it wasn't written by linuxpack (it contains %s and has no %s file)", dir, e.Name(), MarkerFile)
+		}
+	}
+
+	return nil
+}
+
+// create creates an output folder containing the marker file.
+func create(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, MarkerFile), nil, 0644)
+}
+
+// Mode is how a staging directory is moved into the output folder.
+type Mode int
+
+const (
+	// Replace replaces the output folder with the staging directory.
+	Replace Mode = iota
+	// Sync rewrites the files in the output folder which have changed
+	// and deletes the files which are no longer written, leaving
+	// unchanged files untouched.
+	Sync
+	// Merge rewrites the files in the output folder which have changed,
+	// keeping every other file. It is used when the output folder holds
+	// the whole repository and the staging directory only the changes.
+	Merge
+)
+
+// Staging is a directory which a repository is written to
+// before being moved into the output folder.
+type Staging struct {
+	// Dir is the staging directory, which packagers write to.
+	Dir string
+	// out is the output folder.
+	out string
+}
+
+// NewStaging creates a staging directory next to the output folder, so
+// that it is on the same filesystem and can be renamed into place.
+func NewStaging(out string) (*Staging, error) {
+	out = filepath.Clean(out)
+
+	parent := filepath.Dir(out)
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(parent, "."+filepath.Base(out)+".staging-*")
+	if err != nil {
+		return nil, err
+	}
+
+	err = create(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &Staging{Dir: dir, out: out}, nil
+}
+
+// Cleanup deletes the staging directory. It does nothing after Commit.
+func (s *Staging) Cleanup() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// Commit moves the staging directory into the output folder.
+func (s *Staging) Commit(mode Mode) error {
+	switch mode {
+	case Replace:
+		return s.replace()
+	case Sync:
+		return s.copyChanged(true)
+	case Merge:
+		return s.copyChanged(false)
+	}
+	return fmt.Errorf("unknown mode %d", mode)
+}
+
+// replace swaps the output folder for the staging directory. The old
+// output folder is moved aside first, as a directory can't be renamed
+// over a non-empty directory.
+func (s *Staging) replace() error {
+	err := Check(s.out)
+	if err != nil {
+		return err
+	}
+
+	old := s.Dir + ".old"
+
+	err = os.Rename(s.out, old)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.Rename(s.Dir, s.out)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(s.Dir, s.out)
+	if err != nil {
+		// put the old output folder back
+		if restoreErr := os.Rename(old, s.out); restoreErr != nil {
+			return fmt.Errorf("error moving %s into place: %w (the previous output is in %s)", s.Dir, err, old)
+		}
+		return err
+	}
+
+	return os.RemoveAll(old)
+}
+
+// copyChanged copies the files in the staging directory which differ
+// from the output folder. If prune is true, files in the output folder
+// which aren't in the staging directory are deleted.
+func (s *Staging) copyChanged(prune bool) error {
+	if prune {
+		err := Check(s.out)
+		if err != nil {
+			return err
+		}
+	}
+
+	staged, err := listFiles(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range staged {
+		// the marker file is only needed in folders which linuxpack may delete
+		if rel == MarkerFile && !prune {
+			continue
+		}
+
+		err = copyIfChanged(filepath.Join(s.Dir, rel), filepath.Join(s.out, rel))
+		if err != nil {
+			return err
+		}
+	}
+
+	if prune {
+		existing, err := listFiles(s.out)
+		if err != nil {
+			return err
+		}
+
+		for _, rel := range existing {
+			if _, found := slices.BinarySearch(staged, rel); found {
+				continue
+			}
+			err = os.Remove(filepath.Join(s.out, rel))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = removeEmptyDirs(s.out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.Cleanup()
+}
+
+// listFiles returns the sorted paths of the files in dir, relative to dir.
+func listFiles(dir string) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(dir, func(fileName string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && fileName == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, fileName)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+
+	sort.Strings(files)
+	return files, err
+}
+
+// copyIfChanged copies src to dst unless dst already has the same
+// contents. The file is written to a temporary file and renamed into
+// place, so that dst is never partially written.
+func copyIfChanged(src string, dst string) error {
+	same, err := sameContents(src, dst)
+	if err != nil || same {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	_, err = io.Copy(tmp, in)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Chmod(0644)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// sameContents returns true if both files exist and have the same contents.
+func sameContents(a string, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+
+	aFile, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer aFile.Close()
+
+	bFile, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer bFile.Close()
+
+	// packages can be large, so they are compared a chunk at a time
+	aBuf := make([]byte, 64*1024)
+	bBuf := make([]byte, 64*1024)
+	for {
+		n, aErr := io.ReadFull(aFile, aBuf)
+		_, bErr := io.ReadFull(bFile, bBuf[:n])
+		if bErr != nil && bErr != io.ErrUnexpectedEOF && bErr != io.EOF {
+			return false, bErr
+		}
+		if !bytes.Equal(aBuf[:n], bBuf[:n]) {
+			return false, nil
+		}
+		if aErr == io.EOF || aErr == io.ErrUnexpectedEOF {
+			return true, nil
+		}
+		if aErr != nil {
+			return false, aErr
+		}
+	}
+}
+
+// removeEmptyDirs removes the empty directories under dir.
+func removeEmptyDirs(dir string) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(fileName string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && fileName != dir {
+			dirs = append(dirs, fileName)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove the deepest directories first, so that
+	// parents which only contain empty directories are removed
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			err = os.Remove(dirs[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeFiles writes files, given as a map of relative path -> contents, to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns the files in dir as a map of relative path -> contents.
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	names, err := listFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.ToSlash(name)] = string(data)
+	}
+	return files
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			files: map[string]string{},
+		},
+		{
+			name:  "marker",
+			files: map[string]string{MarkerFile: "", "notes.txt": "notes"},
+		},
+		{
+			name:  "known_entries",
+			files: map[string]string{"dists/stable/Release": "release", "pool/amd64/stable/granted.deb": "deb"},
+		},
+		{
+			name:    "other_files",
+			files:   map[string]string{"dists/stable/Release": "release", "notes.txt": "notes"},
+			wantErr: "it contains notes.txt and has no .linuxpack file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			err := Check(dir)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if err := Check(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("expected a missing directory to be writable, got %v", err)
+	}
+}
+
+func TestStaging_Commit(t *testing.T) {
+	existing := map[string]string{
+		MarkerFile:                    "",
+		"dists/stable/Release":        "old release",
+		"pool/amd64/stable/old.deb":   "old",
+		"pool/amd64/stable/same.deb":  "same",
+		"rpm/stable/repodata/old.xml": "old",
+	}
+	staged := map[string]string{
+		"dists/stable/Release":       "new release",
+		"pool/amd64/stable/same.deb": "same",
+		"pool/amd64/stable/new.deb":  "new",
+	}
+
+	tests := []struct {
+		name string
+		mode Mode
+		want map[string]string
+	}{
+		{
+			name: "replace",
+			mode: Replace,
+			want: map[string]string{
+				MarkerFile:                   "",
+				"dists/stable/Release":       "new release",
+				"pool/amd64/stable/same.deb": "same",
+				"pool/amd64/stable/new.deb":  "new",
+			},
+		},
+		{
+			name: "sync",
+			mode: Sync,
+			want: map[string]string{
+				MarkerFile:                   "",
+				"dists/stable/Release":       "new release",
+				"pool/amd64/stable/same.deb": "same",
+				"pool/amd64/stable/new.deb":  "new",
+			},
+		},
+		{
+			name: "merge",
+			mode: Merge,
+			want: map[string]string{
+				MarkerFile:                    "",
+				"dists/stable/Release":        "new release",
+				"pool/amd64/stable/old.deb":   "old",
+				"pool/amd64/stable/same.deb":  "same",
+				"pool/amd64/stable/new.deb":   "new",
+				"rpm/stable/repodata/old.xml": "old",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "dist")
+			writeFiles(t, out, existing)
+
+			// unchanged files keep their modification time when
+			// only changed files are rewritten
+			samePath := filepath.Join(out, "pool", "amd64", "stable", "same.deb")
+			oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if err := os.Chtimes(samePath, oldTime, oldTime); err != nil {
+				t.Fatal(err)
+			}
+
+			stage, err := NewStaging(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stage.Cleanup()
+			writeFiles(t, stage.Dir, staged)
+
+			err = stage.Commit(tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, readFiles(t, out)); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+
+			if _, err := os.Stat(stage.Dir); !os.IsNotExist(err) {
+				t.Errorf("expected the staging directory to be removed, got %v", err)
+			}
+
+			if tt.mode != Replace {
+				info, err := os.Stat(samePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !info.ModTime().Equal(oldTime) {
+					t.Errorf("expected the unchanged file not to be rewritten")
+				}
+			}
+		})
+	}
+}
+
+func TestStaging_Commit_Refuses(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "dist")
+	writeFiles(t, out, map[string]string{"notes.txt": "notes"})
+
+	stage, err := NewStaging(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+
+	for _, mode := range []Mode{Replace, Sync} {
+		err = stage.Commit(mode)
+		if err == nil {
+			t.Errorf("expected mode %d to refuse to delete the output folder", mode)
+		}
+	}
+
+	if diff := cmp.Diff(map[string]string{"notes.txt": "notes"}, readFiles(t, out)); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+}
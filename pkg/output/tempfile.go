@@ -0,0 +1,72 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// TempFile is a package file being copied into an output folder. It is
+// written to a temporary file, so that the file can be copied in the same
+// pass as it is read even when its path depends on its contents, and is
+// renamed into place once complete, so that a failed run never leaves a
+// partially written file.
+type TempFile struct {
+	f *os.File
+}
+
+// CreateTemp creates a temporary file for name in dir, which must be on
+// the same filesystem as the file's final path.
+func CreateTemp(dir string, name string) (*TempFile, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &TempFile{f: f}, nil
+}
+
+func (t *TempFile) Write(p []byte) (int, error) {
+	return t.f.Write(p)
+}
+
+// Commit moves the file to each of fileNames. The file is renamed to the
+// first, and copied to the others.
+func (t *TempFile) Commit(fileNames ...string) error {
+	err := t.f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(t.f.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	for i, fileName := range fileNames {
+		err = os.MkdirAll(filepath.Dir(fileName), 0755)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			err = os.Rename(t.f.Name(), fileName)
+		} else {
+			err = copyIfChanged(fileNames[0], fileName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cleanup deletes the temporary file. It does nothing after Commit.
+func (t *TempFile) Cleanup() {
+	t.f.Close()
+	os.Remove(t.f.Name())
+}
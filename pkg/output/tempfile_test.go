@@ -0,0 +1,56 @@
+package output
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTempFile_Commit(t *testing.T) {
+	dir := t.TempDir()
+
+	tmp, err := CreateTemp(dir, "hello.pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Cleanup()
+
+	_, err = tmp.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tmp.Commit(filepath.Join(dir, "x86_64", "hello.pkg"), filepath.Join(dir, "aarch64", "hello.pkg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Cleanup()
+
+	want := map[string]string{
+		"x86_64/hello.pkg":  "hello",
+		"aarch64/hello.pkg": "hello",
+	}
+	if diff := cmp.Diff(want, readFiles(t, dir)); diff != "" {
+		t.Errorf("files mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTempFile_Cleanup(t *testing.T) {
+	dir := t.TempDir()
+
+	tmp, err := CreateTemp(dir, "hello.pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tmp.Write([]byte("hel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Cleanup()
+
+	if diff := cmp.Diff(map[string]string{}, readFiles(t, dir)); diff != "" {
+		t.Errorf("files mismatch (-want +got):\n%s", diff)
+	}
+}
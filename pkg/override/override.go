@@ -0,0 +1,237 @@
+// Package override loads override files, which set the metadata of
+// packages in the APT indexes by package name. They extend the override
+// files of dpkg-scanpackages, which set the Section, Priority and
+// Maintainer, to the other fields linuxpack writes.
+package override
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"gopkg.in/yaml.v3"
+)
+
+// Override is the metadata set for a package. Empty fields are left unchanged.
+type Override struct {
+	Section    string `yaml:"section"`
+	Priority   string `yaml:"priority"`
+	Maintainer string `yaml:"maintainer"`
+	Licence    string `yaml:"licence"`
+	Vendor     string `yaml:"vendor"`
+	Homepage   string `yaml:"homepage"`
+	// Fields are other control fields, such as Bugs, which are
+	// written after the package's other extra fields in
+	// alphabetical order.
+	Fields map[string]string `yaml:"fields"`
+}
+
+// File maps package names to their overrides.
+type File map[string]Override
+
+// Load loads an override file.
+func Load(fileName string) (File, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	o, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s: %w", fileName, err)
+	}
+	return o, nil
+}
+
+// Parse parses and validates an override file, such as:
+//
+//	granted:
+//	  section: utils
+//	  vendor: Common Fate
+//	  fields:
+//	    Bugs: https://github.com/common-fate/granted/issues
+func Parse(r io.Reader) (File, error) {
+	var f File
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	err := dec.Decode(&f)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return f, f.Validate()
+}
+
+// fieldName is the syntax of control field names.
+var fieldName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// managedFields are written by linuxpack from the package itself, or
+// have their own override keys, so can't be set as extra fields.
+var managedFields = []string{
+	"Package", "Version", "Architecture", "Installed-Size",
+	"Pre-Depends", "Depends", "Recommends", "Suggests",
+	"Conflicts", "Breaks", "Replaces", "Provides",
+	"Description", "Description-md5", "Filename", "Size",
+	"MD5sum", "SHA1", "SHA256", "SHA512",
+	"Section", "Priority", "Maintainer", "Licence", "Vendor", "Homepage",
+}
+
+// Validate returns an error if an override sets an invalid field.
+func (f File) Validate() error {
+	for name, o := range f {
+		values := map[string]string{
+			"section":    o.Section,
+			"priority":   o.Priority,
+			"maintainer": o.Maintainer,
+			"licence":    o.Licence,
+			"vendor":     o.Vendor,
+			"homepage":   o.Homepage,
+		}
+		for key, value := range values {
+			if strings.Contains(value, "\n") {
+				return fmt.Errorf("%s: %s must be a single line", name, key)
+			}
+		}
+
+		for key, value := range o.Fields {
+			if !fieldName.MatchString(key) {
+				return fmt.Errorf("%s: invalid field name %q", name, key)
+			}
+			for _, managed := range managedFields {
+				if strings.EqualFold(key, managed) {
+					return fmt.Errorf("%s: the %s field can't be set in fields", name, managed)
+				}
+			}
+			if value == "" || strings.Contains(value, "\n") {
+				return fmt.Errorf("%s: field %s must be a single line", name, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Change is a field set by an override, which is recorded so that
+// it can be undone when the override is changed or removed.
+type Change struct {
+	Field string `json:"field"`
+	// Original is the value of the field before the override was
+	// applied, or empty if the package didn't have the field.
+	Original string `json:"original,omitempty"`
+	Value    string `json:"value"`
+}
+
+// Apply sets the overridden fields of a package, returning the changes
+// made. Extra fields are written after the package's other extra
+// fields, replacing fields of the same name.
+func (f File) Apply(p *packageset.Package) []Change {
+	o, ok := f[p.Package]
+	if !ok {
+		return nil
+	}
+
+	var changes []Change
+
+	values := []struct {
+		name  string
+		value string
+	}{
+		{"Section", o.Section},
+		{"Priority", o.Priority},
+		{"Maintainer", o.Maintainer},
+		{"Licence", o.Licence},
+		{"Vendor", o.Vendor},
+		{"Homepage", o.Homepage},
+	}
+	for _, v := range values {
+		field := packageField(p, v.name)
+		if v.value == "" || *field == v.value {
+			continue
+		}
+		changes = append(changes, Change{Field: v.name, Original: *field, Value: v.value})
+		*field = v.value
+	}
+
+	var names []string
+	for name := range o.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// packages for the "all" architecture share their fields between
+	// the indexes of every architecture, so they're copied first
+	p.Extra = slices.Clone(p.Extra)
+
+	for _, name := range names {
+		value := o.Fields[name]
+		// field names are case-insensitive, as in control files
+		original, _ := removeField(p, name, "")
+		p.Extra = append(p.Extra, packageset.Field{Name: name, Value: value})
+		if original != value {
+			changes = append(changes, Change{Field: name, Original: original, Value: value})
+		}
+	}
+
+	return changes
+}
+
+// Revert undoes the changes made by an override, restoring the
+// original value of each field, so that the fields of a removed
+// override are restored to the package's own values. Fields which no
+// longer have the value the override set, such as after a rollback,
+// are left unchanged.
+func Revert(p *packageset.Package, changes []Change) {
+	p.Extra = slices.Clone(p.Extra)
+	for _, c := range changes {
+		if field := packageField(p, c.Field); field != nil {
+			if *field == c.Value {
+				*field = c.Original
+			}
+			continue
+		}
+		if _, ok := removeField(p, c.Field, c.Value); ok && c.Original != "" {
+			p.Extra = append(p.Extra, packageset.Field{Name: c.Field, Value: c.Original})
+		}
+	}
+}
+
+// packageField returns the Package field with the given name, or nil
+// for extra fields.
+func packageField(p *packageset.Package, name string) *string {
+	switch name {
+	case "Section":
+		return &p.Section
+	case "Priority":
+		return &p.Priority
+	case "Maintainer":
+		return &p.Maintainer
+	case "Licence":
+		return &p.Licence
+	case "Vendor":
+		return &p.Vendor
+	case "Homepage":
+		return &p.Homepage
+	}
+	return nil
+}
+
+// removeField removes an extra field from a package, returning its
+// value. If value is set, the field is only removed if it has the value.
+func removeField(p *packageset.Package, name string, value string) (string, bool) {
+	for i, f := range p.Extra {
+		if !strings.EqualFold(f.Name, name) || (value != "" && f.Value != value) {
+			continue
+		}
+		p.Extra = slices.Delete(p.Extra, i, i+1)
+		return f.Value, true
+	}
+	return "", false
+}
@@ -0,0 +1,164 @@
+package override
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		give    string
+		want    File
+		wantErr string
+	}{
+		{
+			name: "ok",
+			give: `granted:
+  section: utils
+  vendor: Common Fate
+  fields:
+    Bugs: https://github.com/common-fate/granted/issues
+`,
+			want: File{"granted": {
+				Section: "utils",
+				Vendor:  "Common Fate",
+				Fields:  map[string]string{"Bugs": "https://github.com/common-fate/granted/issues"},
+			}},
+		},
+		{
+			name: "empty",
+			give: "",
+		},
+		{
+			name:    "unknown_key",
+			give:    "granted:\n  sektion: utils\n",
+			wantErr: "field sektion not found",
+		},
+		{
+			name:    "managed_field",
+			give:    "granted:\n  fields:\n    filename: pool/granted.deb\n",
+			wantErr: "granted: the Filename field can't be set in fields",
+		},
+		{
+			name:    "invalid_field_name",
+			give:    "granted:\n  fields:\n    \"X Bugs\": example\n",
+			wantErr: `granted: invalid field name "X Bugs"`,
+		},
+		{
+			name:    "multiline_value",
+			give:    "granted:\n  maintainer: |\n    Common Fate\n    Other\n",
+			wantErr: "granted: maintainer must be a single line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.give))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFile_Apply(t *testing.T) {
+	f := File{"granted": {
+		Section:    "utils",
+		Maintainer: "Granted Team <granted@commonfate.io>",
+		Licence:    "MIT",
+		Fields:     map[string]string{"Tag": "role::program", "Bugs": "https://github.com/common-fate/granted/issues"},
+	}}
+
+	p := packageset.Package{
+		Package:    "granted",
+		Priority:   "optional",
+		Maintainer: "Common Fate <hello@commonfate.io>",
+		Licence:    "Apache-2.0",
+		Vendor:     "Common Fate",
+		Extra:      []packageset.Field{{Name: "tag", Value: "old"}, {Name: "Multi-Arch", Value: "foreign"}},
+	}
+
+	changes := f.Apply(&p)
+
+	// fields which aren't overridden are kept, and extra fields
+	// replace existing fields with the same name
+	want := packageset.Package{
+		Package:    "granted",
+		Section:    "utils",
+		Priority:   "optional",
+		Maintainer: "Granted Team <granted@commonfate.io>",
+		Licence:    "MIT",
+		Vendor:     "Common Fate",
+		Extra: []packageset.Field{
+			{Name: "Multi-Arch", Value: "foreign"},
+			{Name: "Bugs", Value: "https://github.com/common-fate/granted/issues"},
+			{Name: "Tag", Value: "role::program"},
+		},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Errorf("Apply() mismatch (-want +got):\n%s", diff)
+	}
+
+	wantChanges := []Change{
+		{Field: "Section", Value: "utils"},
+		{Field: "Maintainer", Original: "Common Fate <hello@commonfate.io>", Value: "Granted Team <granted@commonfate.io>"},
+		{Field: "Licence", Original: "Apache-2.0", Value: "MIT"},
+		{Field: "Bugs", Value: "https://github.com/common-fate/granted/issues"},
+		{Field: "Tag", Original: "old", Value: "role::program"},
+	}
+	if diff := cmp.Diff(wantChanges, changes); diff != "" {
+		t.Errorf("Apply() changes mismatch (-want +got):\n%s", diff)
+	}
+
+	other := packageset.Package{Package: "granted-completions"}
+	if changes := f.Apply(&other); changes != nil {
+		t.Errorf("expected no changes to granted-completions, got %v", changes)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	p := packageset.Package{
+		Package: "granted",
+		Section: "utils",
+		Licence: "BSD-3-Clause",
+		Extra: []packageset.Field{
+			{Name: "Multi-Arch", Value: "foreign"},
+			{Name: "Bugs", Value: "https://github.com/common-fate/granted/issues"},
+			{Name: "Tag", Value: "role::program"},
+		},
+	}
+
+	// Licence and Tag have been changed since the override set them,
+	// such as by a rollback, so they're left unchanged
+	Revert(&p, []Change{
+		{Field: "Section", Value: "utils"},
+		{Field: "Licence", Original: "Apache-2.0", Value: "MIT"},
+		{Field: "Bugs", Original: "https://example.com/bugs", Value: "https://github.com/common-fate/granted/issues"},
+		{Field: "Tag", Value: "role::cli"},
+	})
+
+	want := []packageset.Field{
+		{Name: "Multi-Arch", Value: "foreign"},
+		{Name: "Tag", Value: "role::program"},
+		{Name: "Bugs", Value: "https://example.com/bugs"},
+	}
+	if diff := cmp.Diff(want, p.Extra); diff != "" {
+		t.Errorf("Revert() mismatch (-want +got):\n%s", diff)
+	}
+	if p.Section != "" || p.Licence != "BSD-3-Clause" {
+		t.Errorf("expected the Section to be restored and the Licence to be unchanged, got %q and %q", p.Section, p.Licence)
+	}
+}
@@ -0,0 +1,238 @@
+package packager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/common-fate/linuxpack/pkg/deb"
+	"github.com/common-fate/linuxpack/pkg/lint"
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/packageset"
+)
+
+// debFile is a .deb package file which has been read,
+// checksummed and copied into the pool.
+type debFile struct {
+	fileName string
+	pkg      packageset.Package
+	ctrl     control.Control
+	// files are the paths installed by the package.
+	files []string
+	// warnings are the lint findings which don't stop publishing.
+	warnings []lint.Finding
+}
+
+// concurrency returns the number of package files processed at once.
+func (p Packager) concurrency() int {
+	if p.Concurrency > 0 {
+		return p.Concurrency
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// processFiles processes the package files using a bounded pool of
+// workers. The results are in the same order as fileNames. Files are
+// started in order and no more are started after one fails, so every file
+// before a failing file is processed, and the error returned is that of
+// the first failing file in fileNames, whichever order files finish in.
+func (p Packager) processFiles(ctx context.Context, fileNames []string) ([]debFile, error) {
+	// files are copied into the pool concurrently, so two files
+	// with the same destination would race to write it
+	err := p.checkDestinations(fileNames)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]debFile, len(fileNames))
+	errs := make([]error, len(fileNames))
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < min(p.concurrency(), len(fileNames)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i], errs[i] = p.processFile(fileNames[i])
+				if errs[i] != nil {
+					// stop starting new files, as the run has failed
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := range fileNames {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	// files which weren't started come after the file which failed
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// checkDestinations returns an error if two package files would be copied
+// to the same path in the pool. The pool path depends on the package
+// architecture, so the control file of each package is read first.
+func (p Packager) checkDestinations(fileNames []string) error {
+	destinations := map[string]string{}
+
+	for _, fileName := range fileNames {
+		arch, err := readArchitecture(fileName)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", fileName, err)
+		}
+
+		dest := p.poolPath(arch, fileName)
+		if other, ok := destinations[dest]; ok {
+			return fmt.Errorf("%s and %s would both be copied to %s", other, fileName, dest)
+		}
+		destinations[dest] = fileName
+	}
+
+	return nil
+}
+
+// readArchitecture reads the Architecture field of a package file.
+func readArchitecture(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := deb.ReadControl(file)
+	if err != nil {
+		return "", err
+	}
+	ctrl, err := control.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return ctrl.Architecture, nil
+}
+
+// poolPath returns the path of a package file in the pool.
+func (p Packager) poolPath(arch string, fileName string) string {
+	return filepath.Join("pool", arch, p.Channel, filepath.Base(fileName))
+}
+
+// processFile reads a package file in a single pass, which parses the
+// package metadata, computes the checksums and copies the file into the
+// pool. The pool path depends on the package architecture, so the file is
+// copied to a temporary file which is renamed once the metadata is read.
+func (p Packager) processFile(fileName string) (debFile, error) {
+	fileInfo, err := os.Stat(fileName)
+	if err != nil {
+		return debFile{}, err
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return debFile{}, err
+	}
+	defer file.Close()
+
+	hasher := checksum.NewHasher(p.hashes()...)
+	w := io.Writer(hasher)
+
+	var tmp *output.TempFile
+	if !p.DryRun {
+		tmp, err = output.CreateTemp(p.OutputFolder, fileInfo.Name())
+		if err != nil {
+			return debFile{}, err
+		}
+		defer tmp.Cleanup()
+
+		w = io.MultiWriter(hasher, tmp)
+	}
+
+	d, err := deb.Read(io.TeeReader(file, w))
+	if err != nil {
+		return debFile{}, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	// deb.Read may not consume the whole file
+	if _, err := io.Copy(w, file); err != nil {
+		return debFile{}, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	sums := hasher.Sums()
+
+	ctrl, err := control.Parse(bytes.NewReader(d.Control))
+	if err != nil {
+		return debFile{}, err
+	}
+
+	findings := lint.Check(lint.Package{FileName: fileInfo.Name(), Control: ctrl, DataSize: d.DataSize}, p.Lint)
+	if errs := lint.Errors(findings); len(errs) > 0 {
+		return debFile{}, lintError(fileName, errs)
+	}
+
+	pkg := packageset.Package{
+		Package:       ctrl.Package,
+		Version:       ctrl.Version,
+		Licence:       p.Licence,
+		Vendor:        p.Vendor,
+		Architecture:  ctrl.Architecture,
+		Maintainer:    ctrl.Maintainer,
+		InstalledSize: ctrl.InstalledSize,
+		PreDepends:    ctrl.PreDepends,
+		Depends:       ctrl.Depends,
+		Recommends:    ctrl.Recommends,
+		Suggests:      ctrl.Suggests,
+		Conflicts:     ctrl.Conflicts,
+		Breaks:        ctrl.Breaks,
+		Replaces:      ctrl.Replaces,
+		Provides:      ctrl.Provides,
+		Section:       ctrl.Section,
+		Priority:      ctrl.Priority,
+		Homepage:      ctrl.Homepage,
+		Description:   ctrl.Description,
+		Size:          fileInfo.Size(),
+		MD5sum:        sums[checksum.MD5],
+		SHA1:          sums[checksum.SHA1],
+		SHA256:        sums[checksum.SHA256],
+		SHA512:        sums[checksum.SHA512],
+		Filename:      p.poolPath(ctrl.Architecture, fileName),
+	}
+
+	if !p.DryRun {
+		err = tmp.Commit(filepath.Join(p.OutputFolder, pkg.Filename))
+		if err != nil {
+			return debFile{}, err
+		}
+	}
+
+	return debFile{fileName: fileName, pkg: pkg, ctrl: ctrl, files: d.Files, warnings: findings}, nil
+}
+
+// lintError returns an error listing the lint errors found in a package.
+func lintError(fileName string, findings []lint.Finding) error {
+	var msgs []string
+	for _, f := range findings {
+		msgs = append(msgs, f.String())
+	}
+	return fmt.Errorf("%s failed lint checks:\n  %s", fileName, strings.Join(msgs, "\n  "))
+}
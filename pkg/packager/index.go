@@ -0,0 +1,202 @@
+package packager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/contents"
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/common-fate/linuxpack/pkg/translation"
+)
+
+// readFormats is the order in which existing indexes are looked up in storage.
+// The uncompressed index is preferred as it doesn't need decompressing.
+var readFormats = []compression.Format{
+	compression.None,
+	compression.Gzip,
+	compression.XZ,
+	compression.Zstd,
+	compression.Bzip2,
+}
+
+// getIndex reads an index file from storage, returning a reader for the
+// decompressed index. As the uncompressed index may not be published,
+// each compression format is tried in turn. If no index exists,
+// storage.ErrNotFound is returned.
+func getIndex(ctx context.Context, store storage.Storage, key string) (io.ReadCloser, error) {
+	for _, format := range readFormats {
+		body, err := store.Get(ctx, key+format.Ext())
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := format.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, fmt.Errorf("error decompressing %s: %w", key+format.Ext(), err)
+		}
+
+		return indexReader{ReadCloser: r, body: body}, nil
+	}
+
+	return nil, storage.ErrNotFound
+}
+
+// indexReader closes both the decompressor and the underlying object body.
+type indexReader struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (r indexReader) Close() error {
+	err := r.ReadCloser.Close()
+	if bodyErr := r.body.Close(); err == nil {
+		err = bodyErr
+	}
+	return err
+}
+
+// ReadPackages reads the Packages index for a component and architecture of
+// a channel from storage. If the index does not exist, storage.ErrNotFound
+// is returned.
+func ReadPackages(ctx context.Context, store storage.Storage, channel string, component string, arch string) (packageset.Set, error) {
+	r, err := getIndex(ctx, store, path.Join("dists", channel, component, "binary-"+arch, "Packages"))
+	if err != nil {
+		return packageset.Set{}, err
+	}
+	defer r.Close()
+
+	return packageset.ReadSet(r)
+}
+
+// ReadExistingRelease reads the Release file of a channel from storage.
+// If the Release file does not exist, storage.ErrNotFound is returned.
+func ReadExistingRelease(ctx context.Context, store storage.Storage, channel string) (Release, error) {
+	body, err := store.Get(ctx, path.Join("dists", channel, "Release"))
+	if err != nil {
+		return Release{}, err
+	}
+	defer body.Close()
+
+	return ReadRelease(body)
+}
+
+// readExistingPackages reads the existing Packages index for a component and architecture from storage.
+func (p Packager) readExistingPackages(ctx context.Context, component string, arch string) (packageset.Set, error) {
+	key := filepath.Join("dists", p.Channel, component, "binary-"+arch, "Packages")
+	fmt.Printf("reading existing packages from %s\n", p.Storage.URL(key))
+
+	set, err := ReadPackages(ctx, p.Storage, p.Channel, component, arch)
+	if err == storage.ErrNotFound {
+		fmt.Printf("no packages found\n")
+		return packageset.Set{}, nil
+	}
+	return set, err
+}
+
+// readExistingTranslation reads the existing Translation-en index for a component from storage.
+func (p Packager) readExistingTranslation(ctx context.Context, component string) (translation.Translation, error) {
+	key := filepath.Join("dists", p.Channel, component, "i18n", "Translation-en")
+	fmt.Printf("reading existing translations from %s\n", p.Storage.URL(key))
+
+	r, err := getIndex(ctx, p.Storage, key)
+	if err == storage.ErrNotFound {
+		fmt.Printf("no translations found\n")
+		return translation.Translation{Lang: "en"}, nil
+	}
+	if err != nil {
+		return translation.Translation{}, err
+	}
+	defer r.Close()
+
+	return translation.Read(r, "en")
+}
+
+// readExistingContents reads the existing Contents index for a component and architecture from storage.
+func (p Packager) readExistingContents(ctx context.Context, component string, arch string) (contents.Contents, error) {
+	key := filepath.Join("dists", p.Channel, component, "Contents-"+arch+".gz")
+	fmt.Printf("reading existing contents from %s\n", p.Storage.URL(key))
+
+	body, err := p.Storage.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		fmt.Printf("no contents found\n")
+		return contents.Contents{}, nil
+	}
+	if err != nil {
+		return contents.Contents{}, err
+	}
+	defer body.Close()
+
+	r, err := compression.Gzip.NewReader(body)
+	if err != nil {
+		return contents.Contents{}, fmt.Errorf("error decompressing %s: %w", key, err)
+	}
+	defer r.Close()
+
+	return contents.Read(r)
+}
+
+// writeContents writes a gzipped Contents index to path.
+func writeContents(path string, c *contents.Contents) error {
+	var buf bytes.Buffer
+	err := c.Write(&buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = writeIndex(strings.TrimSuffix(path, ".gz"), buf.Bytes(), []compression.Format{compression.Gzip})
+	return err
+}
+
+// writeIndex writes data to path in each of the given compression formats,
+// returning the paths of the files which were written.
+func writeIndex(path string, data []byte, formats []compression.Format) ([]string, error) {
+	var paths []string
+
+	for _, format := range formats {
+		formatPath := path + format.Ext()
+
+		f, err := os.Create(formatPath)
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := format.NewWriter(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		_, err = w.Write(data)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		err = w.Close()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error compressing %s: %w", formatPath, err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error closing index file: %w", err)
+		}
+
+		paths = append(paths, formatPath)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,82 @@
+package packager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/override"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// overrideState records the changes which overrides made to the packages
+// in the indexes of a channel, so that they can be undone when an override
+// is changed or removed. It is stored at overrides/<channel>/state.json,
+// outside dists/, so that it isn't downloaded by APT clients.
+type overrideState struct {
+	Packages []overriddenPackage `json:"packages"`
+}
+
+// overriddenPackage is a package in the Packages index of a component
+// and architecture which an override changed.
+type overriddenPackage struct {
+	Component    string            `json:"component"`
+	Architecture string            `json:"architecture"`
+	Package      string            `json:"package"`
+	Version      string            `json:"version"`
+	Changes      []override.Change `json:"changes"`
+}
+
+func overrideStateKey(channel string) string {
+	return path.Join("overrides", channel, "state.json")
+}
+
+// readOverrideState reads the override state of a channel from storage.
+// If no overrides have been applied, an empty state is returned.
+func readOverrideState(ctx context.Context, store storage.Storage, channel string) (overrideState, error) {
+	body, err := store.Get(ctx, overrideStateKey(channel))
+	if err == storage.ErrNotFound {
+		return overrideState{}, nil
+	}
+	if err != nil {
+		return overrideState{}, err
+	}
+	defer body.Close()
+
+	var state overrideState
+	err = json.NewDecoder(body).Decode(&state)
+	if err != nil {
+		return overrideState{}, fmt.Errorf("error reading %s: %w", store.URL(overrideStateKey(channel)), err)
+	}
+	return state, nil
+}
+
+// writeOverrideState writes the override state of a channel to the
+// output folder, sorted so that the file is deterministic.
+func writeOverrideState(outputFolder string, channel string, state overrideState) error {
+	slices.SortFunc(state.Packages, func(a, b overriddenPackage) int {
+		return strings.Compare(
+			strings.Join([]string{a.Component, a.Architecture, a.Package, a.Version}, "\x00"),
+			strings.Join([]string{b.Component, b.Architecture, b.Package, b.Version}, "\x00"),
+		)
+	})
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	statePath := filepath.Join(outputFolder, filepath.FromSlash(overrideStateKey(channel)))
+
+	err = os.MkdirAll(filepath.Dir(statePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(statePath, append(data, '\n'), 0644)
+}
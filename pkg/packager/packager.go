@@ -1,318 +1,587 @@
 package packager
 
 import (
-	"compress/gzip"
+	"bytes"
 	"context"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"errors"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/contents"
+	"github.com/common-fate/linuxpack/pkg/lint"
+	"github.com/common-fate/linuxpack/pkg/override"
 	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/common-fate/linuxpack/pkg/plan"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/common-fate/linuxpack/pkg/translation"
 )
 
 type Packager struct {
-	S3Client     *s3.Client
-	Bucket       string
+	// Storage holds the published repository, which
+	// the new packages are merged with.
+	Storage      storage.Storage
 	Description  string
 	OutputFolder string
 	Licence      string
 	Vendor       string
 	Channel      string
 	Files        []string
+	// Components are the components of the repository. If empty, the
+	// repository has a single "main" component.
+	Components []string
+	// Component is the component which new packages are added to.
+	// Defaults to the first component.
+	Component string
+	// Architectures are the architectures of the repository. Packages
+	// for the "all" architecture are added to every architecture.
+	// If empty, DefaultArchitectures are used.
+	Architectures []string
+	// KeepVersions is the number of versions of each package to keep in
+	// the Packages indexes, removing the oldest. If zero, every version is kept.
+	KeepVersions int
+	// Compression is the set of formats to write the Packages index in.
+	// compression.None writes the uncompressed file. If empty,
+	// uncompressed and gzip indexes are written.
+	Compression []compression.Format
+	// Hashes is the set of hash algorithms used to checksum packages
+	// and index files. If empty, all supported algorithms are used.
+	Hashes []checksum.Algorithm
+	// Contents enables generating Contents-<arch> indexes,
+	// which map file paths to the packages providing them.
+	Contents bool
+	// Translations enables moving long package descriptions out of the
+	// Packages index and into an i18n/Translation-en index.
+	Translations bool
+	// Release configures the fields of the Release file.
+	Release ReleaseConfig
+	// Signer signs the Release file, if set, producing
+	// Release.gpg and InRelease files.
+	Signer signing.Signer
+	// DryRun skips copying packages into the output folder and signing
+	// the Release file, so that changes can be planned without publishing them.
+	DryRun bool
+	// DefaultSection and DefaultPriority are the Section and Priority
+	// of packages in the indexes which don't set them, including
+	// published packages, so that apt frontends can categorise
+	// every package.
+	DefaultSection  string
+	DefaultPriority string
+	// Overrides set the metadata of packages by name. They are applied
+	// to every package in the indexes, so that changing an override
+	// also changes the packages which are already published.
+	Overrides override.File
+	// Lint overrides the severity of the lint rules which packages are
+	// checked against. Packages with lint errors aren't published.
+	Lint lint.Config
+	// Concurrency is the number of package files which are read and
+	// copied at once. Defaults to GOMAXPROCS.
+	Concurrency int
+	// Plan records the changes made to the repository, if set.
+	Plan *plan.Plan
 }
 
-func (p Packager) Package(ctx context.Context) error {
+// DefaultArchitectures are the architectures of
+// the repository if none are configured.
+var DefaultArchitectures = []string{"amd64", "arm64", "i386"}
+
+// components returns the components of the repository.
+func (p Packager) components() []string {
+	if len(p.Components) == 0 {
+		return []string{"main"}
+	}
+	return p.Components
+}
+
+// component returns the component which new packages are added to.
+func (p Packager) component() string {
+	if p.Component == "" {
+		return p.components()[0]
+	}
+	return p.Component
+}
+
+// architectures returns the architectures of the repository.
+func (p Packager) architectures() []string {
+	if len(p.Architectures) == 0 {
+		return DefaultArchitectures
+	}
+	return p.Architectures
+}
+
+// componentIndexes are the indexes of a component of the repository.
+type componentIndexes struct {
 	// map of architecture -> package set
-	sets := map[string]packageset.Set{}
-
-	architectures := []string{"amd64", "arm64", "i386"}
-
-	for _, arch := range architectures {
-		// read the existing packages from the file in S3
-		channelPath := filepath.Join("dists", p.Channel, "main", "binary-"+arch)
-		releaseKey := filepath.Join(channelPath, "Packages")
-		fmt.Printf("reading existing packages from s3://%s/%s\n", p.Bucket, releaseKey)
-		existingReleaseResult, err := p.S3Client.GetObject(ctx, &s3.GetObjectInput{
-			Bucket: &p.Bucket,
-			Key:    &releaseKey,
-		})
-		var nsk *types.NoSuchKey
-		if errors.As(err, &nsk) {
-			fmt.Printf("no packages found\n")
-			sets[arch] = packageset.Set{}
-			continue
+	sets map[string]packageset.Set
+	// map of architecture -> Contents index
+	contents map[string]contents.Contents
+	// map of architecture -> package files added in this run
+	added map[string][]debFile
+	// long descriptions of packages in all architectures,
+	// merged with the existing Translation-en index
+	translations *translation.Translation
+}
+
+// hashes returns the hash algorithms to checksum files with.
+func (p Packager) hashes() []checksum.Algorithm {
+	if len(p.Hashes) == 0 {
+		return checksum.All
+	}
+	return p.Hashes
+}
+
+// compression returns the formats to write indexes in.
+func (p Packager) compression() []compression.Format {
+	if len(p.Compression) == 0 {
+		return []compression.Format{compression.None, compression.Gzip}
+	}
+	return p.Compression
+}
+
+func (p Packager) Package(ctx context.Context) error {
+	err := p.Release.Validate()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(p.components(), p.component()) {
+		return fmt.Errorf("component %q is not one of the repository's components (%s)", p.component(), strings.Join(p.components(), ", "))
+	}
+
+	architectures := p.architectures()
+
+	// map of component -> indexes
+	indexes := map[string]*componentIndexes{}
+
+	for _, component := range p.components() {
+		idx := &componentIndexes{
+			sets:     map[string]packageset.Set{},
+			contents: map[string]contents.Contents{},
+			added:    map[string][]debFile{},
 		}
 
-		if err != nil {
-			return err
+		for _, arch := range architectures {
+			idx.sets[arch], err = p.readExistingPackages(ctx, component, arch)
+			if err != nil {
+				return err
+			}
+
+			if p.Contents {
+				idx.contents[arch], err = p.readExistingContents(ctx, component, arch)
+				if err != nil {
+					return err
+				}
+			}
 		}
-		defer existingReleaseResult.Body.Close()
 
-		sets[arch], err = packageset.ReadSet(existingReleaseResult.Body)
-		if err != nil {
-			return err
+		if p.Translations {
+			t, err := p.readExistingTranslation(ctx, component)
+			if err != nil {
+				return err
+			}
+			idx.translations = &t
 		}
+
+		indexes[component] = idx
 	}
 
-	err := os.RemoveAll(p.OutputFolder)
+	// the changes made by overrides in previous runs are undone, so
+	// that changed and removed overrides are no longer applied
+	state, err := readOverrideState(ctx, p.Storage, p.Channel)
 	if err != nil {
 		return err
 	}
+	for _, o := range state.Packages {
+		idx, ok := indexes[o.Component]
+		if !ok {
+			continue
+		}
+		set := idx.sets[o.Architecture]
+		if pkg, ok := set.Get(o.Package, o.Version); ok {
+			override.Revert(&pkg, o.Changes)
+			set.Add(pkg)
+		}
+	}
 
 	err = os.MkdirAll(p.OutputFolder, 0755)
 	if err != nil {
 		return err
 	}
 
-	for _, fileName := range p.Files {
-		fileInfo, err := os.Stat(fileName)
-		if err != nil {
-			return err
-		}
-		file, err := os.Open(fileName)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		hash := sha1.New()
-		if _, err := io.Copy(hash, file); err != nil {
-			return err
-		}
-		sha1Hash := fmt.Sprintf("%x", hash.Sum(nil))
+	// pool files which may no longer be referenced by any index
+	var unreferenced []string
+
+	// the package files are read, checksummed and copied concurrently,
+	// then added to the indexes in order so the indexes are deterministic
+	debs, err := p.processFiles(ctx, p.Files)
+	if err != nil {
+		return err
+	}
 
-		file.Seek(0, io.SeekStart) // Reset file pointer to beginning for the next hash computation
+	for _, f := range debs {
+		pkg := f.pkg
 
-		hash256 := sha256.New()
-		if _, err := io.Copy(hash256, file); err != nil {
-			return err
+		for _, finding := range f.warnings {
+			fmt.Printf("%s: %s\n", f.fileName, finding)
 		}
-		sha256Hash := fmt.Sprintf("%x", hash256.Sum(nil))
 
-		tmpDir, err := os.MkdirTemp("", "package")
-		if err != nil {
-			return err
+		p.Plan.AddUpload(filepath.ToSlash(pkg.Filename), pkg.Size)
+
+		// packages which aren't architecture-specific
+		// are added to the index of every architecture
+		targets := []string{pkg.Architecture}
+		if pkg.Architecture == "all" {
+			targets = architectures
+		} else if !slices.Contains(architectures, pkg.Architecture) {
+			return fmt.Errorf("%s has architecture %q, which is not one of the repository's architectures (%s)", f.fileName, pkg.Architecture, strings.Join(architectures, ", "))
 		}
-		defer os.RemoveAll(tmpDir)
 
-		c1 := exec.Command("tar", "-z", "-xf", fileName, "--to-stdout", "control.tar.gz")
-		c1.Stderr = os.Stderr
+		idx := indexes[p.component()]
 
-		c2 := exec.Command("tar", "-z", "-xf", "-", "-C", tmpDir)
-		c2.Stderr = os.Stderr
+		for _, arch := range targets {
+			set := idx.sets[arch]
 
-		c2.Stdin, err = c1.StdoutPipe()
-		if err != nil {
-			return err
+			change := plan.PackageChange{
+				Action:  plan.Added,
+				Format:  "deb",
+				Index:   path.Join("dists", p.Channel, p.component(), "binary-"+arch, "Packages"),
+				Name:    pkg.Package,
+				Version: pkg.Version,
+				Arch:    arch,
+			}
+			if existing, ok := set.Get(pkg.Package, pkg.Version); ok {
+				change.Action = plan.Replaced
+				unreferenced = append(unreferenced, existing.Filename)
+			}
+			p.Plan.AddPackage(change)
+
+			set.Add(pkg)
+			idx.sets[arch] = set
+			idx.added[arch] = append(idx.added[arch], f)
 		}
+	}
 
-		err = c2.Start()
-		if err != nil {
-			return err
+	// the defaults and overrides are applied to every package,
+	// including published packages
+	var overridden []overriddenPackage
+	for component, idx := range indexes {
+		for arch, set := range idx.sets {
+			for key, pkg := range set.Packages {
+				if pkg.Section == "" {
+					pkg.Section = p.DefaultSection
+				}
+				if pkg.Priority == "" {
+					pkg.Priority = p.DefaultPriority
+				}
+				if changes := p.Overrides.Apply(&pkg); len(changes) > 0 {
+					overridden = append(overridden, overriddenPackage{
+						Component:    component,
+						Architecture: arch,
+						Package:      pkg.Package,
+						Version:      pkg.Version,
+						Changes:      changes,
+					})
+				}
+				set.Packages[key] = pkg
+			}
 		}
-		err = c1.Run()
-		if err != nil {
-			return err
+	}
+
+	if p.KeepVersions > 0 {
+		for _, component := range p.components() {
+			for _, arch := range architectures {
+				set := indexes[component].sets[arch]
+				for _, removed := range set.Prune(p.KeepVersions) {
+					fmt.Printf("removing %s %s from %s/binary-%s, keeping the latest %d versions\n", removed.Package, removed.Version, component, arch, p.KeepVersions)
+
+					p.Plan.AddPackage(plan.PackageChange{
+						Action:  plan.Removed,
+						Format:  "deb",
+						Index:   path.Join("dists", p.Channel, component, "binary-"+arch, "Packages"),
+						Name:    removed.Package,
+						Version: removed.Version,
+						Arch:    arch,
+					})
+					unreferenced = append(unreferenced, removed.Filename)
+				}
+			}
+		}
+	}
+
+	// pool files of removed and replaced packages can be deleted,
+	// unless they are still referenced by another index or a snapshot
+	referenced := map[string]bool{}
+	for _, idx := range indexes {
+		for _, set := range idx.sets {
+			for _, pkg := range set.Packages {
+				referenced[pkg.Filename] = true
+			}
 		}
-		err = c2.Wait()
+	}
+	// snapshots can be rolled back to, so the files they reference are kept
+	if len(unreferenced) > 0 {
+		snapshotted, err := snapshotReferences(ctx, p.Storage, p.Channel)
 		if err != nil {
 			return err
 		}
+		for fileName := range snapshotted {
+			referenced[fileName] = true
+		}
+	}
 
-		controlFile, err := os.Open(filepath.Join(tmpDir, "control"))
-		if err != nil {
-			return err
+	for _, fileName := range unreferenced {
+		if !referenced[fileName] {
+			p.Plan.AddDelete(filepath.ToSlash(fileName))
 		}
-		defer controlFile.Close()
+	}
+
+	// create the Release file
+	release := p.newRelease(architectures, time.Now().UTC())
 
-		ctrl, err := control.Parse(controlFile)
+	distPath := filepath.Join(p.OutputFolder, "dists", p.Channel)
+
+	for _, component := range p.components() {
+		err = p.writeComponent(&release, distPath, component, indexes[component])
 		if err != nil {
 			return err
 		}
+	}
 
-		pkg := packageset.Package{
-			Package:       ctrl.Package,
-			Version:       ctrl.Version,
-			Licence:       p.Licence,
-			Vendor:        p.Vendor,
-			Architecture:  ctrl.Architecture,
-			Maintainer:    ctrl.Maintainer,
-			InstalledSize: ctrl.InstalledSize,
-			Priority:      ctrl.Priority,
-			Homepage:      ctrl.Homepage,
-			Description:   ctrl.Description,
-			Size:          fileInfo.Size(),
-			SHA1:          sha1Hash,
-			SHA256:        sha256Hash,
-			Filename:      filepath.Join("pool", ctrl.Architecture, p.Channel, fileInfo.Name()),
-		}
-
-		pathToCopy := filepath.Join(p.OutputFolder, pkg.Filename)
-		// copy the file over to the Filename path
-		err = os.MkdirAll(filepath.Dir(pathToCopy), 0755)
-		if err != nil {
-			return err
+	// the changes are recorded for the packages which are still published
+	if len(state.Packages) > 0 || len(overridden) > 0 {
+		var published []overriddenPackage
+		for _, o := range overridden {
+			set := indexes[o.Component].sets[o.Architecture]
+			if _, ok := set.Get(o.Package, o.Version); ok {
+				published = append(published, o)
+			}
 		}
 
-		destFile, err := os.Create(pathToCopy)
+		err = writeOverrideState(p.OutputFolder, p.Channel, overrideState{Packages: published})
 		if err != nil {
 			return err
 		}
-		defer destFile.Close()
+	}
 
-		file.Seek(0, io.SeekStart) // Reset file pointer to beginning for copying
-		if _, err := io.Copy(destFile, file); err != nil {
-			return err
-		}
+	releasePath := filepath.Join(distPath, "Release")
 
-		set := sets[pkg.Architecture]
-		set.Add(pkg)
-		sets[pkg.Architecture] = set
+	releaseFile, err := os.Create(releasePath)
+	if err != nil {
+		return err
 	}
+	defer releaseFile.Close()
 
-	var md5Checksums []Checksum
-	var sha1Checksums []Checksum
-	var sha256Checksums []Checksum
+	err = release.Write(releaseFile)
+	if err != nil {
+		return err
+	}
 
-	for _, arch := range architectures {
-		arch := arch
-		channelPath := filepath.Join(p.OutputFolder, "dists", p.Channel, "main", "binary-"+arch)
-		packagePath := filepath.Join(channelPath, "Packages")
+	err = releaseFile.Close()
+	if err != nil {
+		return fmt.Errorf("error closing release file: %w", err)
+	}
 
-		err = os.MkdirAll(channelPath, 0755)
+	if p.Signer != nil && !p.DryRun {
+		err = p.Signer.DetachSign(ctx, releasePath, filepath.Join(distPath, "Release.gpg"), true)
 		if err != nil {
 			return err
 		}
 
-		packageFile, err := os.Create(packagePath)
+		err = p.Signer.ClearSign(ctx, releasePath, filepath.Join(distPath, "InRelease"))
 		if err != nil {
 			return err
 		}
-		defer packageFile.Close()
+	}
 
-		// Create the gzipped version of the Packages file
-		gzipPath := packagePath + ".gz"
-		gzipFile, err := os.Create(gzipPath)
-		if err != nil {
-			return err
-		}
-		defer gzipFile.Close()
+	return nil
+}
 
-		gzipWriter := gzip.NewWriter(gzipFile)
+// writeComponent writes the indexes of a component, recording
+// their checksums in the Release.
+func (p Packager) writeComponent(release *Release, distPath string, component string, idx *componentIndexes) error {
+	// the translations which are referenced by a Packages index
+	usedTranslations := map[translation.Key]bool{}
 
-		set := sets[arch]
-		err = set.Write(io.MultiWriter(packageFile, gzipWriter))
-		if err != nil {
-			return err
-		}
+	for _, arch := range p.architectures() {
+		channelPath := filepath.Join(distPath, component, "binary-"+arch)
 
-		err = gzipFile.Sync()
+		err := os.MkdirAll(channelPath, 0755)
 		if err != nil {
 			return err
 		}
 
-		err = packageFile.Sync()
+		var buf bytes.Buffer
+		set := prepareDescriptions(idx.sets[arch], idx.translations, usedTranslations)
+		err = set.Write(&buf)
 		if err != nil {
 			return err
 		}
 
-		err = packageFile.Close()
-		if err != nil {
-			return fmt.Errorf("error closing package file: %w", err)
-		}
-
-		err = gzipWriter.Close()
+		paths, err := writeIndex(filepath.Join(channelPath, "Packages"), buf.Bytes(), p.compression())
 		if err != nil {
 			return err
 		}
 
-		err = gzipFile.Close()
+		// Calculate the checksums of each of the index files
+		err = p.addChecksums(release, distPath, paths...)
 		if err != nil {
 			return err
 		}
 
-		// Calculate the md5, sha1, and sha256 sums of the packagePath and gzipPath
-
-		paths := []string{packagePath, gzipPath}
+		if p.Contents {
+			contentsPath := filepath.Join(distPath, component, "Contents-"+arch+".gz")
 
-		for _, path := range paths {
-
-			file, err := os.Open(path)
+			c := idx.contents[arch]
+			updateContents(&c, idx.sets[arch], idx.added[arch])
+			err = writeContents(contentsPath, &c)
 			if err != nil {
-				return fmt.Errorf("error opening file %s: %w", path, err)
-			}
-			defer file.Close()
-
-			hashMd5 := md5.New()
-			hashSha1 := sha1.New()
-			hashSha256 := sha256.New()
-
-			if _, err := io.Copy(io.MultiWriter(hashMd5, hashSha1, hashSha256), file); err != nil {
 				return err
 			}
 
-			fileInfo, err := file.Stat()
+			err = p.addChecksums(release, distPath, contentsPath)
 			if err != nil {
 				return err
 			}
-			fmt.Printf("path: %s, size = %v\n", path, fileInfo.Size())
-
-			md5Checksums = append(md5Checksums, Checksum{
-				Sum:  fmt.Sprintf("%x", hashMd5.Sum(nil)),
-				Size: fileInfo.Size(),
-				Path: strings.TrimPrefix(path, "dist/dists/stable/"),
-			})
-			sha1Checksums = append(sha1Checksums, Checksum{
-				Sum:  fmt.Sprintf("%x", hashSha1.Sum(nil)),
-				Size: fileInfo.Size(),
-				Path: strings.TrimPrefix(path, "dist/dists/stable/"),
-			})
-			sha256Checksums = append(sha256Checksums, Checksum{
-				Sum:  fmt.Sprintf("%x", hashSha256.Sum(nil)),
-				Size: fileInfo.Size(),
-				Path: strings.TrimPrefix(path, "dist/dists/stable/"),
-			})
 		}
 	}
 
-	// create the Release file
-	release := Release{
-		Origin:        p.Vendor + " APT Repository",
-		Label:         p.Vendor,
-		Suite:         p.Channel,
-		Codename:      p.Channel,
-		Version:       "1.0",
-		Architectures: architectures,
-		Components:    "main",
-		Description:   p.Description,
-		Date:          time.Now().UTC(),
-		MD5Sums:       md5Checksums,
-		SHA1Sums:      sha1Checksums,
-		SHA256Sums:    sha256Checksums,
-	}
-
-	releasePath := filepath.Join(p.OutputFolder, "dists", "stable", "Release")
+	translations := idx.translations
+	if translations == nil {
+		return nil
+	}
 
-	releaseFile, err := os.Create(releasePath)
+	// drop descriptions which are no longer referenced by any package
+	for key := range translations.Descriptions {
+		if !usedTranslations[key] {
+			delete(translations.Descriptions, key)
+		}
+	}
+
+	i18nPath := filepath.Join(distPath, component, "i18n")
+	err := os.MkdirAll(i18nPath, 0755)
 	if err != nil {
 		return err
 	}
-	defer releaseFile.Close()
 
-	err = release.Write(releaseFile)
+	var buf bytes.Buffer
+	err = translations.Write(&buf)
 	if err != nil {
 		return err
 	}
 
+	paths, err := writeIndex(filepath.Join(i18nPath, "Translation-en"), buf.Bytes(), p.compression())
+	if err != nil {
+		return err
+	}
+
+	return p.addChecksums(release, distPath, paths...)
+}
+
+// addChecksums computes the checksums of index files and records them in the
+// Release. distPath is the directory containing the Release file.
+func (p Packager) addChecksums(release *Release, distPath string, paths ...string) error {
+	for _, path := range paths {
+		sums, size, err := checksum.File(path, p.hashes()...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("path: %s, size = %v\n", path, size)
+
+		relPath, err := filepath.Rel(distPath, path)
+		if err != nil {
+			return err
+		}
+
+		release.AddChecksums(filepath.ToSlash(relPath), size, sums)
+	}
+
 	return nil
 }
+
+// newRelease returns the Release for the channel, applying defaults for
+// any fields which aren't configured.
+func (p Packager) newRelease(architectures []string, date time.Time) Release {
+	release := Release{
+		Origin:               p.Release.Origin,
+		Label:                p.Release.Label,
+		Suite:                p.Channel,
+		Codename:             p.Channel,
+		Version:              p.Release.Version,
+		Architectures:        architectures,
+		Components:           strings.Join(p.components(), " "),
+		Description:          p.Description,
+		Date:                 date,
+		NotAutomatic:         p.Release.NotAutomatic,
+		ButAutomaticUpgrades: p.Release.ButAutomaticUpgrades,
+		SignedBy:             p.Release.SignedBy,
+	}
+
+	if release.Origin == "" {
+		release.Origin = p.Vendor + " APT Repository"
+	}
+	if release.Label == "" {
+		release.Label = p.Vendor
+	}
+	if release.Version == "" {
+		release.Version = "1.0"
+	}
+	if p.Release.ValidFor > 0 {
+		release.ValidUntil = date.Add(p.Release.ValidFor)
+	}
+
+	return release
+}
+
+// updateContents updates a Contents index from the packages in a set, so
+// that it only lists the packages which are still published. The index
+// doesn't record which version of a package provides a file, so the files
+// of a package are replaced by those of the new package files when every
+// version in the set was added in this run. Otherwise, the files of the
+// new versions are added to those of the existing versions.
+func updateContents(c *contents.Contents, set packageset.Set, added []debFile) {
+	// map of package name -> versions in the set
+	versions := map[string][]string{}
+	for _, pkg := range set.Packages {
+		versions[pkg.Package] = append(versions[pkg.Package], pkg.Version)
+	}
+
+	for _, name := range c.Packages() {
+		if _, ok := versions[name]; !ok {
+			c.Remove(name)
+		}
+	}
+
+	// map of package name -> version -> added package file
+	files := map[string]map[string]debFile{}
+	for _, f := range added {
+		if files[f.pkg.Package] == nil {
+			files[f.pkg.Package] = map[string]debFile{}
+		}
+		files[f.pkg.Package][f.pkg.Version] = f
+	}
+
+	for name, byVersion := range files {
+		rebuild := true
+		for _, version := range versions[name] {
+			if _, ok := byVersion[version]; !ok {
+				rebuild = false
+			}
+		}
+		if rebuild {
+			c.Remove(name)
+		}
+
+		// versions which were added and then pruned aren't included
+		for _, version := range versions[name] {
+			if f, ok := byVersion[version]; ok {
+				c.Add(contents.Location(f.ctrl.Section, name), f.files)
+			}
+		}
+	}
+}
@@ -0,0 +1,842 @@
+package packager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/control"
+	"github.com/common-fate/linuxpack/pkg/deb"
+	"github.com/common-fate/linuxpack/pkg/lint"
+	"github.com/common-fate/linuxpack/pkg/override"
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/common-fate/linuxpack/pkg/plan"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+// memStorage is an in-memory storage.Storage for tests.
+type memStorage map[string][]byte
+
+func (m memStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m memStorage) URL(key string) string {
+	return "mem://" + key
+}
+
+// writeDeb builds a .deb package in dir, returning its path.
+func writeDeb(t *testing.T, dir string, name string, version string, arch string) string {
+	t.Helper()
+
+	return writeDebControl(t, dir, control.Control{
+		Package:      name,
+		Version:      version,
+		Architecture: arch,
+		Maintainer:   "Common Fate <hello@commonfate.io>",
+		Description:  "The easiest way to access your cloud.",
+	})
+}
+
+// writeDebControl builds a .deb package with the given
+// control fields in dir, returning its path.
+func writeDebControl(t *testing.T, dir string, ctrl control.Control) string {
+	t.Helper()
+
+	fileName := filepath.Join(dir, ctrl.Package+"_"+ctrl.Version+"_"+ctrl.Architecture+".deb")
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = deb.Write(f, deb.Archive{
+		Control: ctrl,
+		Files:   []deb.File{{Name: "/usr/bin/" + ctrl.Package, Mode: 0755, Data: []byte("#!/bin/sh\n")}},
+		ModTime: time.Unix(1700000000, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestPackager_Package(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	store := memStorage{
+		"dists/stable/extra/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+	}
+
+	p := Packager{
+		Storage:       store,
+		OutputFolder:  out,
+		Vendor:        "Common Fate",
+		Channel:       "stable",
+		Components:    []string{"main", "extra"},
+		Component:     "extra",
+		Architectures: []string{"amd64", "arm64"},
+		KeepVersions:  1,
+		Files: []string{
+			writeDeb(t, dir, "granted", "0.27.5", "amd64"),
+			writeDeb(t, dir, "granted-completions", "0.27.5", "all"),
+		},
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(out, "dists", "stable", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	amd64 := read("extra/binary-amd64/Packages")
+	if strings.Contains(amd64, "Version: 0.27.4") {
+		t.Errorf("expected 0.27.4 to be removed by retention, got:\n%s", amd64)
+	}
+	for _, want := range []string{"Package: granted\nVersion: 0.27.5", "Package: granted-completions"} {
+		if !strings.Contains(amd64, want) {
+			t.Errorf("expected extra/binary-amd64/Packages to contain %q, got:\n%s", want, amd64)
+		}
+	}
+
+	// packages for the "all" architecture are added to every architecture
+	if arm64 := read("extra/binary-arm64/Packages"); !strings.Contains(arm64, "Package: granted-completions") || strings.Contains(arm64, "Package: granted\n") {
+		t.Errorf("unexpected extra/binary-arm64/Packages:\n%s", arm64)
+	}
+
+	if main := read("main/binary-amd64/Packages"); main != "" {
+		t.Errorf("expected main/binary-amd64/Packages to be empty, got:\n%s", main)
+	}
+
+	release := read("Release")
+	for _, want := range []string{"Architectures: amd64 arm64\n", "Components: main extra\n", " main/binary-arm64/Packages\n"} {
+		if !strings.Contains(release, want) {
+			t.Errorf("expected Release to contain %q, got:\n%s", want, release)
+		}
+	}
+}
+
+func TestPackager_Package_ContentsRetention(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	// granted-old is only provided by 0.27.4, and removed is
+	// no longer in the Packages index
+	var existing bytes.Buffer
+	zw := gzip.NewWriter(&existing)
+	io.WriteString(zw, "usr/bin/granted granted\nusr/bin/granted-old granted\nusr/bin/removed removed\n")
+	zw.Close()
+
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+		"dists/stable/main/Contents-amd64.gz": existing.Bytes(),
+	}
+
+	p := Packager{
+		Storage:       store,
+		OutputFolder:  out,
+		Channel:       "stable",
+		Architectures: []string{"amd64"},
+		KeepVersions:  1,
+		Contents:      true,
+		Files:         []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(out, "dists", "stable", "main", "Contents-amd64.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.27.4 was pruned, so only the files of 0.27.5 are listed
+	if diff := cmp.Diff("usr/bin/granted granted\n", string(got)); diff != "" {
+		t.Errorf("Contents mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPackager_Package_UnknownArchitecture(t *testing.T) {
+	dir := t.TempDir()
+
+	p := Packager{
+		Storage:       memStorage{},
+		OutputFolder:  filepath.Join(dir, "dist"),
+		Channel:       "stable",
+		Architectures: []string{"amd64"},
+		Files:         []string{writeDeb(t, dir, "granted", "0.27.5", "riscv64")},
+	}
+
+	err := p.Package(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `has architecture "riscv64"`) {
+		t.Errorf("expected an unknown architecture error, got %v", err)
+	}
+}
+
+func TestPackager_Package_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+	}
+
+	pl := plan.New()
+	p := Packager{
+		Storage:       store,
+		OutputFolder:  out,
+		Channel:       "stable",
+		Architectures: []string{"amd64"},
+		KeepVersions:  1,
+		Files:         []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+		DryRun:        true,
+		Plan:          pl,
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// packages are not copied in a dry run
+	if _, err := os.Stat(filepath.Join(out, "pool")); !os.IsNotExist(err) {
+		t.Errorf("expected no pool directory in a dry run, got %v", err)
+	}
+
+	wantPackages := []plan.PackageChange{
+		{Action: plan.Added, Format: "deb", Index: "dists/stable/main/binary-amd64/Packages", Name: "granted", Version: "0.27.5", Arch: "amd64"},
+		{Action: plan.Removed, Format: "deb", Index: "dists/stable/main/binary-amd64/Packages", Name: "granted", Version: "0.27.4", Arch: "amd64"},
+	}
+	if diff := cmp.Diff(wantPackages, pl.Packages); diff != "" {
+		t.Errorf("packages mismatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"pool/amd64/stable/granted_0.27.4_amd64.deb"}, pl.Deletes); diff != "" {
+		t.Errorf("deletes mismatch (-want +got):\n%s", diff)
+	}
+
+	if len(pl.Uploads) != 1 || pl.Uploads[0].Key != "pool/amd64/stable/granted_0.27.5_amd64.deb" {
+		t.Errorf("unexpected uploads %v", pl.Uploads)
+	}
+}
+
+func TestPackager_Package_KeepsSnapshotted(t *testing.T) {
+	dir := t.TempDir()
+
+	packages := []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`)
+
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages":        packages,
+		"snapshots/stable/index.json":                    []byte(`{"snapshots": [{"name": "v1", "channel": "stable", "files": ["Release", "main/binary-amd64/Packages"]}]}`),
+		"snapshots/stable/v1/main/binary-amd64/Packages": packages,
+		"snapshots/stable/v1/Release":                    []byte("Suite: stable\n"),
+	}
+
+	pl := plan.New()
+	p := Packager{
+		Storage:       store,
+		OutputFolder:  filepath.Join(dir, "dist"),
+		Channel:       "stable",
+		Architectures: []string{"amd64"},
+		KeepVersions:  1,
+		Files:         []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+		DryRun:        true,
+		Plan:          pl,
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pruned package is referenced by a snapshot, so it isn't deleted
+	if len(pl.Deletes) != 0 {
+		t.Errorf("expected no deletes, got %v", pl.Deletes)
+	}
+}
+
+func TestPackager_Package_InPlace(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+
+	// the repository is read from and written back to the same directory
+	for _, version := range []string{"0.27.4", "0.27.5"} {
+		p := Packager{
+			Storage:       storage.Local{Dir: repo},
+			OutputFolder:  repo,
+			Channel:       "stable",
+			Architectures: []string{"amd64"},
+			Files:         []string{writeDeb(t, dir, "granted", version, "amd64")},
+		}
+
+		err := p.Package(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"granted_0.27.4_amd64.deb", "granted_0.27.5_amd64.deb"} {
+		if _, err := os.Stat(filepath.Join(repo, "pool", "amd64", "stable", name)); err != nil {
+			t.Errorf("expected %s to be kept in the pool: %v", name, err)
+		}
+	}
+
+	packages, err := os.ReadFile(filepath.Join(repo, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(packages), "Package: granted\n"); n != 2 {
+		t.Errorf("expected both versions in the Packages index, got %d:\n%s", n, packages)
+	}
+}
+
+func TestPackager_Package_Concurrency(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 10; i++ {
+		files = append(files, writeDeb(t, dir, fmt.Sprintf("granted-%d", i), "0.27.5", "amd64"))
+	}
+
+	// the output is the same however many files are processed at once
+	var indexes []string
+	for _, concurrency := range []int{1, 4, 16} {
+		out := filepath.Join(dir, fmt.Sprintf("dist-%d", concurrency))
+		p := Packager{
+			Storage:      memStorage{},
+			OutputFolder: out,
+			Channel:      "stable",
+			Files:        files,
+			Concurrency:  concurrency,
+		}
+
+		err := p.Package(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, string(data))
+
+		for _, fileName := range files {
+			want, err := os.ReadFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(out, "pool", "amd64", "stable", filepath.Base(fileName)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("concurrency %d: pool copy of %s differs from the package", concurrency, filepath.Base(fileName))
+			}
+		}
+
+		// no temporary files are left behind
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if strings.Contains(e.Name(), ".tmp-") {
+				t.Errorf("concurrency %d: unexpected temporary file %s", concurrency, e.Name())
+			}
+		}
+	}
+
+	for i := 1; i < len(indexes); i++ {
+		if diff := cmp.Diff(indexes[0], indexes[i]); diff != "" {
+			t.Errorf("Packages index differs with concurrency (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestPackager_Package_ConcurrencyError(t *testing.T) {
+	dir := t.TempDir()
+
+	invalid := func(name string) string {
+		fileName := filepath.Join(dir, name)
+		err := os.WriteFile(fileName, []byte("not a deb"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+
+	var files []string
+	for i := 0; i < 8; i++ {
+		files = append(files, writeDeb(t, dir, fmt.Sprintf("granted-%d", i), "0.27.5", "amd64"))
+	}
+	files = append(files, invalid("first.deb"), invalid("second.deb"))
+
+	p := Packager{
+		Storage:      memStorage{},
+		OutputFolder: filepath.Join(dir, "dist"),
+		Channel:      "stable",
+		Files:        files,
+		Concurrency:  4,
+	}
+
+	// the error is reported for the first invalid file in the list
+	err := p.Package(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "first.deb") {
+		t.Fatalf("expected an error reading first.deb, got %v", err)
+	}
+}
+
+func TestPackager_Package_DuplicateDestination(t *testing.T) {
+	dir := t.TempDir()
+
+	// packages with the same file name and architecture are copied
+	// to the same path in the pool, even from different folders
+	var files []string
+	for _, sub := range []string{"a", "b"} {
+		err := os.Mkdir(filepath.Join(dir, sub), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, writeDeb(t, filepath.Join(dir, sub), "granted", "0.27.5", "amd64"))
+	}
+
+	p := Packager{
+		Storage:      memStorage{},
+		OutputFolder: filepath.Join(dir, "dist"),
+		Channel:      "stable",
+		Files:        files,
+		Concurrency:  2,
+	}
+
+	err := p.Package(context.Background())
+	want := fmt.Sprintf("%s and %s would both be copied to %s", files[0], files[1], filepath.Join("pool", "amd64", "stable", "granted_0.27.5_amd64.deb"))
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+
+	// nothing is copied into the pool
+	_, err = os.Stat(filepath.Join(dir, "dist", "pool"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected the pool not to exist, got %v", err)
+	}
+}
+
+func TestPackager_Package_Lint(t *testing.T) {
+	dir := t.TempDir()
+
+	p := Packager{
+		Storage:      memStorage{},
+		OutputFolder: filepath.Join(dir, "dist"),
+		Channel:      "stable",
+		Files:        []string{writeDeb(t, dir, "granted", "v0.27.5", "amd64")},
+	}
+
+	err := p.Package(context.Background())
+	want := "failed lint checks:\n  error: invalid version \"v0.27.5\": the upstream version must start with a digit (version)"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected a lint error, got %v", err)
+	}
+
+	// turning the rule off publishes the package
+	p.Lint = lint.Config{"version": lint.Off}
+	err = p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackager_Package_Relationships(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	p := Packager{
+		Storage:      memStorage{},
+		OutputFolder: out,
+		Channel:      "stable",
+		Files: []string{writeDebControl(t, dir, control.Control{
+			Package:      "granted",
+			Version:      "0.27.5",
+			Architecture: "amd64",
+			Maintainer:   "Common Fate <hello@commonfate.io>",
+			PreDepends:   "dpkg (>= 1.17.14)",
+			Depends:      "libc6, curl",
+			Recommends:   "awscli",
+			Conflicts:    "granted-legacy",
+			Provides:     "assume",
+			Description:  "The easiest way to access your cloud.",
+		})},
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Pre-Depends: dpkg (>= 1.17.14)\nDepends: libc6, curl\nRecommends: awscli\nConflicts: granted-legacy\nProvides: assume\n"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("expected Packages to contain %q, got:\n%s", want, data)
+	}
+}
+
+func TestPackager_Package_Defaults(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	// the published package was added before the defaults were set
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Priority: extra
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+	}
+
+	p := Packager{
+		Storage:         store,
+		OutputFolder:    out,
+		Channel:         "stable",
+		Files:           []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+		DefaultSection:  "utils",
+		DefaultPriority: "optional",
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := packageset.ReadSet(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the packages don't set a Section, so the default is used,
+	// and the Priority of the published package is kept
+	want := map[string][2]string{"0.27.4": {"utils", "extra"}, "0.27.5": {"utils", "optional"}}
+	for version, fields := range want {
+		pkg, ok := set.Get("granted", version)
+		if !ok {
+			t.Fatalf("expected granted %s in the index", version)
+		}
+		if got := [2]string{pkg.Section, pkg.Priority}; got != fields {
+			t.Errorf("expected granted %s to have Section and Priority %v, got %v", version, fields, got)
+		}
+	}
+}
+
+func TestPackager_Package_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	// the published package is overridden too
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Licence: Apache-2.0
+Architecture: amd64
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+	}
+
+	p := Packager{
+		Storage:      store,
+		OutputFolder: out,
+		Channel:      "stable",
+		Licence:      "Apache-2.0",
+		Vendor:       "Common Fate",
+		Files:        []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+		Overrides: override.File{"granted": {
+			Licence: "MIT",
+			Fields:  map[string]string{"Bugs": "https://github.com/common-fate/granted/issues"},
+		}},
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := packageset.ReadSet(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"0.27.4", "0.27.5"} {
+		pkg, ok := set.Get("granted", version)
+		if !ok {
+			t.Fatalf("expected granted %s in the index", version)
+		}
+		if pkg.Licence != "MIT" {
+			t.Errorf("expected granted %s to have the overridden licence, got %q", version, pkg.Licence)
+		}
+	}
+	if !strings.Contains(string(data), "Bugs: https://github.com/common-fate/granted/issues\n") {
+		t.Errorf("expected the Bugs field to be written, got:\n%s", data)
+	}
+	// the fields set by overrides are recorded outside the index
+	if strings.Contains(string(data), "Linuxpack") {
+		t.Errorf("expected no linuxpack fields in the index, got:\n%s", data)
+	}
+	_, err = os.Stat(filepath.Join(out, "overrides", "stable", "state.json"))
+	if err != nil {
+		t.Errorf("expected the override state to be written: %s", err)
+	}
+}
+
+func TestPackager_Package_RemovedOverrideField(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	// Bugs and Tag were set by an override file, which is
+	// recorded in the override state rather than the index
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Description: The easiest way to access your cloud.
+Multi-Arch: foreign
+Bugs: https://github.com/common-fate/granted/issues
+Tag: role::program
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+		"overrides/stable/state.json": []byte(`{"packages": [{
+	"component": "main", "architecture": "amd64", "package": "granted", "version": "0.27.4",
+	"changes": [
+		{"field": "Bugs", "value": "https://github.com/common-fate/granted/issues"},
+		{"field": "Tag", "value": "role::program"}
+	]
+}]}`),
+	}
+
+	// Bugs has been removed from the override file
+	p := Packager{
+		Storage:      store,
+		OutputFolder: out,
+		Channel:      "stable",
+		Files:        []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+		Overrides:    override.File{"granted": {Fields: map[string]string{"Tag": "role::program"}}},
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := packageset.ReadSet(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, ok := set.Get("granted", "0.27.4")
+	if !ok {
+		t.Fatal("expected granted 0.27.4 in the index")
+	}
+
+	// fields which weren't set by the override file are kept
+	want := []packageset.Field{{Name: "Multi-Arch", Value: "foreign"}, {Name: "Tag", Value: "role::program"}}
+	if diff := cmp.Diff(want, pkg.Extra); diff != "" {
+		t.Errorf("Extra mismatch (-want +got):\n%s", diff)
+	}
+
+	state, err := readOverrideState(context.Background(), storage.Local{Dir: out}, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []string
+	for _, o := range state.Packages {
+		changed = append(changed, o.Package+" "+o.Version)
+	}
+	if diff := cmp.Diff([]string{"granted 0.27.4", "granted 0.27.5"}, changed); diff != "" {
+		t.Errorf("override state mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPackager_Package_RemovedOverride(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	// the override of granted has been removed from the override file
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Licence: MIT
+Architecture: amd64
+Section: utils
+Description: The easiest way to access your cloud.
+Bugs: https://github.com/common-fate/granted/issues
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+		"overrides/stable/state.json": []byte(`{"packages": [{
+	"component": "main", "architecture": "amd64", "package": "granted", "version": "0.27.4",
+	"changes": [
+		{"field": "Section", "value": "utils"},
+		{"field": "Licence", "original": "Apache-2.0", "value": "MIT"},
+		{"field": "Bugs", "value": "https://github.com/common-fate/granted/issues"}
+	]
+}]}`),
+	}
+
+	p := Packager{
+		Storage:        store,
+		OutputFolder:   out,
+		Channel:        "stable",
+		Files:          []string{writeDeb(t, dir, "assume", "0.1.0", "amd64")},
+		DefaultSection: "misc",
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := packageset.ReadSet(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, ok := set.Get("granted", "0.27.4")
+	if !ok {
+		t.Fatal("expected granted 0.27.4 in the index")
+	}
+
+	// the package's own values are restored, and the
+	// defaults apply to fields it didn't set
+	if pkg.Section != "misc" || pkg.Licence != "Apache-2.0" || pkg.Extra != nil {
+		t.Errorf("expected the override to be removed, got Section %q, Licence %q and %v", pkg.Section, pkg.Licence, pkg.Extra)
+	}
+
+	state, err := readOverrideState(context.Background(), storage.Local{Dir: out}, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Packages) != 0 {
+		t.Errorf("expected the override state to be empty, got %v", state.Packages)
+	}
+}
+
+func TestPackager_Package_KeepsExtraFields(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dist")
+
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages": []byte(`Package: granted
+Version: 0.27.4
+Architecture: amd64
+Description: The easiest way to access your cloud.
+Multi-Arch: foreign
+Filename: pool/amd64/stable/granted_0.27.4_amd64.deb
+Size: 100
+
+`),
+	}
+
+	p := Packager{
+		Storage:      store,
+		OutputFolder: out,
+		Channel:      "stable",
+		Files:        []string{writeDeb(t, dir, "granted", "0.27.5", "amd64")},
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "dists", "stable", "main", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fields of the published package which linuxpack doesn't handle are kept
+	if !strings.Contains(string(data), "Multi-Arch: foreign\n") {
+		t.Errorf("expected the Multi-Arch field to be kept, got:\n%s", data)
+	}
+}
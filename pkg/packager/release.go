@@ -1,10 +1,15 @@
 package packager
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
 )
 
 type Release struct {
@@ -17,9 +22,35 @@ type Release struct {
 	Components    string
 	Description   string
 	Date          time.Time
-	MD5Sums       []Checksum
-	SHA1Sums      []Checksum
-	SHA256Sums    []Checksum
+	// ValidUntil is the time after which clients should consider the
+	// Release stale. It is omitted if zero.
+	ValidUntil           time.Time
+	NotAutomatic         bool
+	ButAutomaticUpgrades bool
+	// SignedBy are the fingerprints of the keys which clients
+	// should accept signatures from.
+	SignedBy   []string
+	MD5Sums    []Checksum
+	SHA1Sums   []Checksum
+	SHA256Sums []Checksum
+	SHA512Sums []Checksum
+}
+
+// AddChecksums records the checksums of an index file in the Release.
+// path is relative to the directory containing the Release file.
+func (r *Release) AddChecksums(path string, size int64, sums checksum.Sums) {
+	add := func(checksums []Checksum, a checksum.Algorithm) []Checksum {
+		sum, ok := sums[a]
+		if !ok {
+			return checksums
+		}
+		return append(checksums, Checksum{Sum: sum, Size: size, Path: path})
+	}
+
+	r.MD5Sums = add(r.MD5Sums, checksum.MD5)
+	r.SHA1Sums = add(r.SHA1Sums, checksum.SHA1)
+	r.SHA256Sums = add(r.SHA256Sums, checksum.SHA256)
+	r.SHA512Sums = add(r.SHA512Sums, checksum.SHA512)
 }
 
 func (r *Release) Write(w io.Writer) error {
@@ -68,35 +99,199 @@ func (r *Release) Write(w io.Writer) error {
 		return err
 	}
 
-	var md5sums []string
-	var sha1sums []string
-	var sha256sums []string
+	if !r.ValidUntil.IsZero() {
+		_, err = fmt.Fprintf(w, "Valid-Until: %s\n", r.ValidUntil.Format(time.RFC1123))
+		if err != nil {
+			return err
+		}
+	}
 
-	for _, s := range r.MD5Sums {
-		md5sums = append(md5sums, fmt.Sprintf(" %s %v %s", s.Sum, s.Size, s.Path))
+	if r.NotAutomatic {
+		_, err = fmt.Fprintf(w, "NotAutomatic: yes\n")
+		if err != nil {
+			return err
+		}
 	}
 
-	for _, s := range r.SHA1Sums {
-		sha1sums = append(sha1sums, fmt.Sprintf(" %s %v %s", s.Sum, s.Size, s.Path))
+	if r.ButAutomaticUpgrades {
+		_, err = fmt.Fprintf(w, "ButAutomaticUpgrades: yes\n")
+		if err != nil {
+			return err
+		}
 	}
 
-	for _, s := range r.SHA256Sums {
-		sha256sums = append(sha256sums, fmt.Sprintf(" %s %v %s", s.Sum, s.Size, s.Path))
+	if len(r.SignedBy) > 0 {
+		_, err = fmt.Fprintf(w, "Signed-By: %s\n", strings.Join(r.SignedBy, ", "))
+		if err != nil {
+			return err
+		}
 	}
 
-	_, err = fmt.Fprintf(w, "MD5Sum:\n%s\n", strings.Join(md5sums, "\n"))
-	if err != nil {
-		return err
+	blocks := []struct {
+		name      string
+		checksums []Checksum
+	}{
+		{name: "MD5Sum", checksums: r.MD5Sums},
+		{name: "SHA1", checksums: r.SHA1Sums},
+		{name: "SHA256", checksums: r.SHA256Sums},
+		{name: "SHA512", checksums: r.SHA512Sums},
 	}
-	_, err = fmt.Fprintf(w, "SHA1:\n%s\n", strings.Join(sha1sums, "\n"))
-	if err != nil {
-		return err
+
+	for _, b := range blocks {
+		// blocks are omitted entirely if the algorithm is disabled
+		if len(b.checksums) == 0 {
+			continue
+		}
+
+		var lines []string
+		for _, s := range b.checksums {
+			lines = append(lines, fmt.Sprintf(" %s %v %s", s.Sum, s.Size, s.Path))
+		}
+
+		_, err = fmt.Fprintf(w, "%s:\n%s\n", b.name, strings.Join(lines, "\n"))
+		if err != nil {
+			return err
+		}
 	}
-	_, err = fmt.Fprintf(w, "SHA256:\n%s\n", strings.Join(sha256sums, "\n"))
+
+	return nil
+}
+
+// ReadRelease reads a Release file, such as one written by Write.
+// Fields which linuxpack doesn't write are ignored.
+func ReadRelease(r io.Reader) (Release, error) {
+	var release Release
+
+	sc := bufio.NewScanner(r)
+
+	// the checksum block currently being read
+	var block *[]Checksum
+
+	var lineNum int
+	for sc.Scan() {
+		lineNum++
+		line := sc.Text()
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") {
+			if block == nil {
+				continue
+			}
+
+			parts := strings.Fields(line)
+			if len(parts) != 3 {
+				return Release{}, fmt.Errorf("invalid checksum on line %v: %q", lineNum, line)
+			}
+			size, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return Release{}, fmt.Errorf("invalid checksum size on line %v: %w", lineNum, err)
+			}
+			*block = append(*block, Checksum{Sum: parts[0], Size: size, Path: parts[2]})
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return Release{}, fmt.Errorf("invalid line %v: did not contain a \":\" separator: %q", lineNum, line)
+		}
+		value = strings.TrimSpace(value)
+		block = nil
+
+		var err error
+		switch key {
+		case "Origin":
+			release.Origin = value
+		case "Label":
+			release.Label = value
+		case "Suite":
+			release.Suite = value
+		case "Codename":
+			release.Codename = value
+		case "Version":
+			release.Version = value
+		case "Architectures":
+			release.Architectures = strings.Fields(value)
+		case "Components":
+			release.Components = value
+		case "Description":
+			release.Description = value
+		case "Date":
+			release.Date, err = parseReleaseTime(value)
+		case "Valid-Until":
+			release.ValidUntil, err = parseReleaseTime(value)
+		case "NotAutomatic":
+			release.NotAutomatic = value == "yes"
+		case "ButAutomaticUpgrades":
+			release.ButAutomaticUpgrades = value == "yes"
+		case "Signed-By":
+			for _, fingerprint := range strings.Split(value, ",") {
+				release.SignedBy = append(release.SignedBy, strings.TrimSpace(fingerprint))
+			}
+		case "MD5Sum":
+			block = &release.MD5Sums
+		case "SHA1":
+			block = &release.SHA1Sums
+		case "SHA256":
+			block = &release.SHA256Sums
+		case "SHA512":
+			block = &release.SHA512Sums
+		}
+		if err != nil {
+			return Release{}, fmt.Errorf("invalid %s on line %v: %w", key, lineNum, err)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return Release{}, err
+	}
+
+	return release, nil
+}
+
+// parseReleaseTime parses a date in a Release file, which is
+// RFC 1123 formatted with either a numeric or named zone.
+func parseReleaseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC1123, value)
 	if err != nil {
-		return err
+		t, err = time.Parse(time.RFC1123Z, value)
 	}
+	return t, err
+}
 
+// ReleaseConfig configures the repository-level fields of the Release file.
+type ReleaseConfig struct {
+	// Origin defaults to "<Vendor> APT Repository".
+	Origin string
+	// Label defaults to the vendor.
+	Label string
+	// Version defaults to "1.0".
+	Version string
+	// ValidFor sets the Valid-Until field to the release date plus
+	// the duration, which protects clients against replay and freeze
+	// attacks. Valid-Until is omitted if zero.
+	ValidFor time.Duration
+	// NotAutomatic prevents apt from installing packages from the
+	// repository unless explicitly requested, which is useful for
+	// opt-in channels such as beta.
+	NotAutomatic bool
+	// ButAutomaticUpgrades allows upgrades of packages which were
+	// installed from a NotAutomatic repository.
+	ButAutomaticUpgrades bool
+	// SignedBy are the fingerprints of the keys used to sign the repository.
+	SignedBy []string
+}
+
+// Validate checks that the configuration is consistent.
+func (c ReleaseConfig) Validate() error {
+	if c.ButAutomaticUpgrades && !c.NotAutomatic {
+		return errors.New("ButAutomaticUpgrades requires NotAutomatic to be set")
+	}
+	if c.ValidFor < 0 {
+		return fmt.Errorf("ValidFor must not be negative: %s", c.ValidFor)
+	}
 	return nil
 }
 
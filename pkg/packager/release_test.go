@@ -0,0 +1,74 @@
+package packager
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRelease_Write(t *testing.T) {
+	date := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+	p := Packager{
+		Vendor:      "Common Fate",
+		Channel:     "beta",
+		Description: "Granted beta releases",
+		Release: ReleaseConfig{
+			ValidFor:             7 * 24 * time.Hour,
+			NotAutomatic:         true,
+			ButAutomaticUpgrades: true,
+			SignedBy:             []string{"0123456789ABCDEF0123456789ABCDEF01234567"},
+		},
+	}
+
+	release := p.newRelease([]string{"amd64", "arm64"}, date)
+	release.AddChecksums("main/binary-amd64/Packages", 42, checksum.Sums{
+		checksum.MD5:    "5d41402abc4b2a76b9719d911017c592",
+		checksum.SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	})
+
+	var buf bytes.Buffer
+	if err := release.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Origin: Common Fate APT Repository
+Label: Common Fate
+Suite: beta
+Codename: beta
+Version: 1.0
+Architectures: amd64 arm64
+Components: main
+Description: Granted beta releases
+Date: Sat, 17 Oct 2026 09:30:00 UTC
+Valid-Until: Sat, 24 Oct 2026 09:30:00 UTC
+NotAutomatic: yes
+ButAutomaticUpgrades: yes
+Signed-By: 0123456789ABCDEF0123456789ABCDEF01234567
+MD5Sum:
+ 5d41402abc4b2a76b9719d911017c592 42 main/binary-amd64/Packages
+SHA256:
+ 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 42 main/binary-amd64/Packages
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Write() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err := ReadRelease(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(release, got); diff != "" {
+		t.Errorf("ReadRelease() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReleaseConfig_Validate(t *testing.T) {
+	err := ReleaseConfig{ButAutomaticUpgrades: true}.Validate()
+	if err == nil {
+		t.Error("expected an error when ButAutomaticUpgrades is set without NotAutomatic")
+	}
+}
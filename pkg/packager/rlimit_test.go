@@ -0,0 +1,39 @@
+//go:build unix
+
+package packager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/common-fate/linuxpack/internal/testutil"
+)
+
+// TestPackager_Package_ManyFiles packages many more files than can be
+// open at once, which fails if file handles are held until Package returns.
+func TestPackager_Package_ManyFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []string
+	for i := 0; i < 300; i++ {
+		files = append(files, writeDeb(t, dir, fmt.Sprintf("granted-%d", i), "0.27.5", "amd64"))
+	}
+
+	testutil.SetNoFileLimit(t, 64)
+
+	p := Packager{
+		Storage:      memStorage{},
+		OutputFolder: filepath.Join(dir, "dist"),
+		Channel:      "stable",
+		Files:        files,
+		Contents:     true,
+		Concurrency:  4,
+	}
+
+	err := p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,509 @@
+package packager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// Snapshot is a copy of the index files of a channel at a point in time,
+// which the channel can be rolled back to.
+//
+// The files of a snapshot are stored under snapshots/<channel>/<name>/,
+// with the same layout as dists/<channel>/.
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Channel string    `json:"channel"`
+	Created time.Time `json:"created"`
+	// Files are the paths of the index files, relative to dists/<channel>.
+	Files []string `json:"files"`
+	// Suites are the suites which the snapshot has been published as.
+	Suites []string `json:"suites,omitempty"`
+}
+
+// SnapshotIndex lists the snapshots of a channel. It is
+// stored at snapshots/<channel>/index.json.
+type SnapshotIndex struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// Get returns the named snapshot.
+func (s SnapshotIndex) Get(name string) (Snapshot, bool) {
+	for _, snapshot := range s.Snapshots {
+		if snapshot.Name == name {
+			return snapshot, true
+		}
+	}
+	return Snapshot{}, false
+}
+
+// Open returns a view of storage in which the snapshot's files
+// are in dists/<channel>/, so that the snapshot can be read like the
+// published channel, such as with ReadExistingRelease and ReadPackages.
+func (s Snapshot) Open(store storage.Storage) storage.Storage {
+	return snapshotStorage{store: store, snapshot: s}
+}
+
+// snapshotStorage is the storage returned by Snapshot.Open.
+type snapshotStorage struct {
+	store    storage.Storage
+	snapshot Snapshot
+}
+
+// key returns the key of the snapshot file for a key in dists/<channel>/.
+// Other keys are unchanged.
+func (s snapshotStorage) key(key string) string {
+	dist := path.Join("dists", s.snapshot.Channel)
+	if key == dist {
+		return s.snapshot.key("")
+	}
+	file, found := strings.CutPrefix(key, dist+"/")
+	if !found {
+		return key
+	}
+	return s.snapshot.key(file)
+}
+
+func (s snapshotStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.store.Get(ctx, s.key(key))
+}
+
+func (s snapshotStorage) URL(key string) string {
+	return s.store.URL(s.key(key))
+}
+
+// snapshotIndexKey returns the key of the snapshot index of a channel.
+func snapshotIndexKey(channel string) string {
+	return path.Join("snapshots", channel, "index.json")
+}
+
+// key returns the key of a file in the snapshot.
+func (s Snapshot) key(file string) string {
+	return path.Join("snapshots", s.Channel, s.Name, file)
+}
+
+// ReadSnapshots reads the snapshot index of a channel from storage.
+// If the channel has no snapshots, an empty index is returned.
+func ReadSnapshots(ctx context.Context, store storage.Storage, channel string) (SnapshotIndex, error) {
+	body, err := store.Get(ctx, snapshotIndexKey(channel))
+	if err == storage.ErrNotFound {
+		return SnapshotIndex{}, nil
+	}
+	if err != nil {
+		return SnapshotIndex{}, err
+	}
+	defer body.Close()
+
+	var index SnapshotIndex
+	err = json.NewDecoder(body).Decode(&index)
+	if err != nil {
+		return SnapshotIndex{}, fmt.Errorf("error reading %s: %w", store.URL(snapshotIndexKey(channel)), err)
+	}
+	return index, nil
+}
+
+// validateSnapshotName returns an error if a snapshot name
+// can't be used as a path segment.
+func validateSnapshotName(name string) error {
+	if name == "" || name == "." || name == ".." || name == "index.json" || strings.ContainsAny(name, "/\\ ") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// CreateSnapshot copies the published index files of a channel into
+// snapshots/<channel>/<name>/ in outputFolder, and writes the snapshot
+// index with the new snapshot added. The output folder is replaced once
+// every file has been copied, so that it can be synced to storage.
+//
+// The files copied are the Release file, its signatures, and every
+// index listed in the Release file.
+func CreateSnapshot(ctx context.Context, store storage.Storage, outputFolder string, channel string, name string, created time.Time) (Snapshot, error) {
+	err := validateSnapshotName(name)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	index, err := ReadSnapshots(ctx, store, channel)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if _, ok := index.Get(name); ok {
+		return Snapshot{}, fmt.Errorf("snapshot %q of channel %s already exists", name, channel)
+	}
+
+	release, err := ReadExistingRelease(ctx, store, channel)
+	if err == storage.ErrNotFound {
+		return Snapshot{}, fmt.Errorf("channel %s has not been published: %s does not exist", channel, store.URL(path.Join("dists", channel, "Release")))
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{
+		Name:    name,
+		Channel: channel,
+		Created: created,
+	}
+
+	files := []string{"Release", "Release.gpg", "InRelease"}
+	for _, block := range [][]Checksum{release.MD5Sums, release.SHA1Sums, release.SHA256Sums, release.SHA512Sums} {
+		for _, c := range block {
+			if !slices.Contains(files, c.Path) {
+				files = append(files, c.Path)
+			}
+		}
+	}
+
+	stage, err := stageOutput(outputFolder)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer stage.Cleanup()
+
+	for _, file := range files {
+		key := path.Join("dists", channel, file)
+
+		err = copyObject(ctx, store, key, filepath.Join(stage.Dir, filepath.FromSlash(snapshot.key(file))))
+		// the repository may not be signed
+		if err == storage.ErrNotFound && (file == "Release.gpg" || file == "InRelease") {
+			continue
+		}
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("error copying %s: %w", store.URL(key), err)
+		}
+
+		fmt.Printf("copied %s\n", store.URL(key))
+		snapshot.Files = append(snapshot.Files, file)
+	}
+
+	index.Snapshots = append(index.Snapshots, snapshot)
+
+	err = writeSnapshotIndex(stage.Dir, channel, index)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	err = stage.Commit(output.Replace)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// Rollback copies the index files of a snapshot into dists/<channel>/ in
+// outputFolder, so that syncing the output folder to storage republishes
+// them. The output folder is replaced once every file has been copied.
+//
+// Every file is copied before anything is written to storage, so a failed
+// rollback leaves the published channel unchanged.
+func Rollback(ctx context.Context, store storage.Storage, outputFolder string, channel string, name string) (Snapshot, error) {
+	index, err := ReadSnapshots(ctx, store, channel)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot, ok := index.Get(name)
+	if !ok {
+		return Snapshot{}, fmt.Errorf("snapshot %q of channel %s does not exist", name, channel)
+	}
+
+	stage, err := stageOutput(outputFolder)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer stage.Cleanup()
+
+	for _, file := range snapshot.Files {
+		key := snapshot.key(file)
+
+		err = copyObject(ctx, store, key, filepath.Join(stage.Dir, "dists", channel, filepath.FromSlash(file)))
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("error copying %s: %w", store.URL(key), err)
+		}
+		fmt.Printf("copied %s\n", store.URL(key))
+	}
+
+	release, err := readReleaseFile(filepath.Join(stage.Dir, "dists", channel, "Release"))
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	// the signed Release can't be changed, so a snapshot
+	// which has expired will be rejected by clients
+	if !release.ValidUntil.IsZero() && release.ValidUntil.Before(time.Now()) {
+		fmt.Printf("warning: the Release file of snapshot %q expired at %s, so clients will reject it\n", name, release.ValidUntil.Format(time.RFC1123))
+	}
+
+	err = stage.Commit(output.Replace)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// PublishSnapshot publishes a snapshot as its own suite, such as
+// stable-2024-03-01, so that clients can pin to a fixed state of the
+// channel. The snapshot's indexes are copied into dists/<suite>/ in
+// outputFolder and a new Release file is written for the suite, which
+// is signed if signer is set. The packages stay in the channel's pool,
+// and are kept while the snapshot references them. The output folder
+// is replaced once the suite has been written.
+//
+// Published suites are immutable, so the suite must not already exist.
+func PublishSnapshot(ctx context.Context, store storage.Storage, outputFolder string, channel string, name string, suite string, signer signing.Signer) (Snapshot, error) {
+	err := validateSnapshotName(suite)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("invalid suite: %w", err)
+	}
+	if suite == channel {
+		return Snapshot{}, fmt.Errorf("suite %q must be different to the channel", suite)
+	}
+
+	index, err := ReadSnapshots(ctx, store, channel)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot, ok := index.Get(name)
+	if !ok {
+		return Snapshot{}, fmt.Errorf("snapshot %q of channel %s does not exist", name, channel)
+	}
+
+	_, err = ReadExistingRelease(ctx, store, suite)
+	if err == nil {
+		return Snapshot{}, fmt.Errorf("suite %s has already been published and can't be changed", suite)
+	}
+	if err != storage.ErrNotFound {
+		return Snapshot{}, err
+	}
+
+	stage, err := stageOutput(outputFolder)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer stage.Cleanup()
+
+	distPath := filepath.Join(stage.Dir, "dists", suite)
+
+	var release Release
+	for _, file := range snapshot.Files {
+		key := snapshot.key(file)
+
+		switch file {
+		case "Release":
+			body, err := store.Get(ctx, key)
+			if err != nil {
+				return Snapshot{}, fmt.Errorf("error reading %s: %w", store.URL(key), err)
+			}
+			release, err = ReadRelease(body)
+			body.Close()
+			if err != nil {
+				return Snapshot{}, fmt.Errorf("error reading %s: %w", store.URL(key), err)
+			}
+			continue
+		case "Release.gpg", "InRelease":
+			// the suite's Release is different, so it's signed again
+			continue
+		}
+
+		err = copyObject(ctx, store, key, filepath.Join(distPath, filepath.FromSlash(file)))
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("error copying %s: %w", store.URL(key), err)
+		}
+		fmt.Printf("copied %s\n", store.URL(key))
+	}
+
+	if !slices.Contains(snapshot.Files, "Release") {
+		return Snapshot{}, fmt.Errorf("snapshot %q has no Release file", name)
+	}
+
+	// the index files are unchanged, so the checksums in the Release
+	// are still valid. The suite must be valid forever, so it has no
+	// Valid-Until date.
+	release.Suite = suite
+	release.Codename = suite
+	release.ValidUntil = time.Time{}
+	if release.Description == "" {
+		release.Description = fmt.Sprintf("Snapshot %s of %s", name, channel)
+	} else {
+		release.Description = fmt.Sprintf("%s (snapshot %s)", release.Description, name)
+	}
+
+	releasePath := filepath.Join(distPath, "Release")
+
+	err = os.MkdirAll(distPath, 0755)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	releaseFile, err := os.Create(releasePath)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer releaseFile.Close()
+
+	err = release.Write(releaseFile)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	err = releaseFile.Close()
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("error closing release file: %w", err)
+	}
+
+	if signer != nil {
+		err = signer.DetachSign(ctx, releasePath, filepath.Join(distPath, "Release.gpg"), true)
+		if err != nil {
+			return Snapshot{}, err
+		}
+
+		err = signer.ClearSign(ctx, releasePath, filepath.Join(distPath, "InRelease"))
+		if err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	for i := range index.Snapshots {
+		if index.Snapshots[i].Name == name {
+			index.Snapshots[i].Suites = append(index.Snapshots[i].Suites, suite)
+			snapshot = index.Snapshots[i]
+		}
+	}
+
+	err = writeSnapshotIndex(stage.Dir, channel, index)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	err = stage.Commit(output.Replace)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// snapshotReferences returns the pool files referenced by the
+// Packages indexes of every snapshot of a channel.
+func snapshotReferences(ctx context.Context, store storage.Storage, channel string) (map[string]bool, error) {
+	index, err := ReadSnapshots(ctx, store, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{}
+
+	for _, snapshot := range index.Snapshots {
+		// the Packages index may be stored in several compression formats
+		read := map[string]bool{}
+
+		for _, file := range snapshot.Files {
+			base := strings.TrimSuffix(file, compression.FromExt(file).Ext())
+			if path.Base(base) != "Packages" || read[base] {
+				continue
+			}
+			read[base] = true
+
+			r, err := getIndex(ctx, store, snapshot.key(base))
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", store.URL(snapshot.key(base)), err)
+			}
+
+			set, err := packageset.ReadSet(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pkg := range set.Packages {
+				referenced[pkg.Filename] = true
+			}
+		}
+	}
+
+	return referenced, nil
+}
+
+// writeSnapshotIndex writes the snapshot index of a channel to the output folder.
+func writeSnapshotIndex(outputFolder string, channel string, index SnapshotIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	indexPath := filepath.Join(outputFolder, filepath.FromSlash(snapshotIndexKey(channel)))
+
+	err = os.MkdirAll(filepath.Dir(indexPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(indexPath, append(data, '\n'), 0644)
+}
+
+// readReleaseFile reads a Release file from disk.
+func readReleaseFile(fileName string) (Release, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return Release{}, err
+	}
+	defer f.Close()
+
+	return ReadRelease(f)
+}
+
+// stageOutput checks that the output folder can be replaced, then creates
+// the staging directory which the files are written to, so that a failed
+// run leaves the previous output in place.
+func stageOutput(outputFolder string) (*output.Staging, error) {
+	err := output.Check(outputFolder)
+	if err != nil {
+		return nil, err
+	}
+	return output.NewStaging(outputFolder)
+}
+
+// copyObject copies an object from storage to a file. If the
+// object does not exist, storage.ErrNotFound is returned.
+func copyObject(ctx context.Context, store storage.Storage, key string, fileName string) error {
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	err = os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, body)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,219 @@
+package packager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	release := `Suite: stable
+MD5Sum:
+ 0f343b0931126a20f133d67c2b018a3b 8 main/binary-amd64/Packages
+ d41d8cd98f00b204e9800998ecf8427e 0 main/binary-amd64/Packages.gz
+SHA256:
+ 74234e98afe7498fb5daf1f36ac2d78acc339464f950703b8c019892f982b90b 8 main/binary-amd64/Packages
+`
+	store := memStorage{
+		"dists/stable/Release":                       []byte(release),
+		"dists/stable/InRelease":                     []byte("signed " + release),
+		"dists/stable/main/binary-amd64/Packages":    []byte("Package: granted\n"),
+		"dists/stable/main/binary-amd64/Packages.gz": []byte("gzipped"),
+	}
+
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	snapshotDir := filepath.Join(dir, "snapshot")
+	got, err := CreateSnapshot(ctx, store, snapshotDir, "stable", "v1", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Snapshot{
+		Name:    "v1",
+		Channel: "stable",
+		Created: created,
+		// Release.gpg is skipped as it wasn't published
+		Files: []string{"Release", "InRelease", "main/binary-amd64/Packages", "main/binary-amd64/Packages.gz"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("snapshot mismatch (-want +got):\n%s", diff)
+	}
+
+	// roll back from the synced snapshot after the channel has changed
+	published := storage.Local{Dir: snapshotDir}
+
+	index, err := ReadSnapshots(ctx, published, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(SnapshotIndex{Snapshots: []Snapshot{want}}, index); diff != "" {
+		t.Errorf("snapshot index mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = CreateSnapshot(ctx, published, filepath.Join(dir, "again"), "stable", "v1", created)
+	if err == nil {
+		t.Errorf("expected an error creating a duplicate snapshot")
+	}
+
+	rollbackDir := filepath.Join(dir, "rollback")
+	_, err = Rollback(ctx, published, rollbackDir, "stable", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, data := range store {
+		got, err := os.ReadFile(filepath.Join(rollbackDir, filepath.FromSlash(key)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) {
+			t.Errorf("expected %s to be %q, got %q", key, data, got)
+		}
+	}
+
+	_, err = Rollback(ctx, published, rollbackDir, "stable", "v2")
+	if err == nil {
+		t.Errorf("expected an error rolling back to a snapshot which doesn't exist")
+	}
+
+	// a failed rollback leaves the previous output in place
+	err = os.Remove(filepath.Join(snapshotDir, "snapshots", "stable", "v1", "main", "binary-amd64", "Packages.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Rollback(ctx, published, rollbackDir, "stable", "v1")
+	if err == nil {
+		t.Fatal("expected an error rolling back to a snapshot with a missing file")
+	}
+	if _, err := os.Stat(filepath.Join(rollbackDir, "dists", "stable", "main", "binary-amd64", "Packages.gz")); err != nil {
+		t.Errorf("expected the previous rollback to be kept: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("expected the staging directory to be removed, found %s", e.Name())
+		}
+	}
+}
+
+func TestSnapshot_Open(t *testing.T) {
+	ctx := context.Background()
+
+	store := memStorage{
+		"dists/stable/main/binary-amd64/Packages":           []byte("Package: granted\nVersion: 0.27.5\nArchitecture: amd64\n"),
+		"snapshots/stable/v1/main/binary-amd64/Packages":    []byte("Package: granted\nVersion: 0.27.4\nArchitecture: amd64\n"),
+		"snapshots/stable/v1/main/binary-amd64/Packages.gz": []byte("not read, as the uncompressed index exists"),
+	}
+
+	snapshot := Snapshot{Name: "v1", Channel: "stable"}
+	view := snapshot.Open(store)
+
+	set, err := ReadPackages(ctx, view, "stable", "main", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := set.Get("granted", "0.27.4"); !ok || len(set.Packages) != 1 {
+		t.Errorf("expected the snapshot's packages, got %v", set.Packages)
+	}
+
+	if got := view.URL("dists/stable/Release"); got != "mem://snapshots/stable/v1/Release" {
+		t.Errorf("unexpected URL %q", got)
+	}
+	if got := view.URL("dists/stable"); got != "mem://snapshots/stable/v1" {
+		t.Errorf("unexpected URL %q", got)
+	}
+}
+
+// fakeSigner writes placeholder signatures.
+type fakeSigner struct{}
+
+func (fakeSigner) DetachSign(ctx context.Context, in, out string, armor bool) error {
+	return os.WriteFile(out, []byte("signature"), 0644)
+}
+
+func (fakeSigner) ClearSign(ctx context.Context, in, out string) error {
+	return os.WriteFile(out, []byte("clearsigned"), 0644)
+}
+
+func TestPublishSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := memStorage{
+		"snapshots/stable/index.json": []byte(`{"snapshots": [{"name": "v1", "channel": "stable", "files": ["Release", "InRelease", "main/binary-amd64/Packages"]}]}`),
+		"snapshots/stable/v1/Release": []byte(`Suite: stable
+Codename: stable
+Architectures: amd64
+Components: main
+Description: Granted
+Date: Thu, 01 Feb 2024 12:00:00 UTC
+Valid-Until: Fri, 01 Mar 2024 12:00:00 UTC
+SHA256:
+ 74234e98afe7498fb5daf1f36ac2d78acc339464f950703b8c019892f982b90b 8 main/binary-amd64/Packages
+`),
+		"snapshots/stable/v1/InRelease":                  []byte("signed"),
+		"snapshots/stable/v1/main/binary-amd64/Packages": []byte("Package: granted\n"),
+	}
+
+	out := filepath.Join(dir, "dist")
+	snapshot, err := PublishSnapshot(ctx, store, out, "stable", "v1", "stable-v1", fakeSigner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"stable-v1"}, snapshot.Suites); diff != "" {
+		t.Errorf("suites mismatch (-want +got):\n%s", diff)
+	}
+
+	release, err := readReleaseFile(filepath.Join(out, "dists", "stable-v1", "Release"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Release{
+		Suite:         "stable-v1",
+		Codename:      "stable-v1",
+		Architectures: []string{"amd64"},
+		Components:    "main",
+		Description:   "Granted (snapshot v1)",
+		Date:          time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC),
+		SHA256Sums:    []Checksum{{Sum: "74234e98afe7498fb5daf1f36ac2d78acc339464f950703b8c019892f982b90b", Size: 8, Path: "main/binary-amd64/Packages"}},
+	}
+	if diff := cmp.Diff(want, release); diff != "" {
+		t.Errorf("release mismatch (-want +got):\n%s", diff)
+	}
+
+	for name, want := range map[string]string{
+		"main/binary-amd64/Packages": "Package: granted\n",
+		"InRelease":                  "clearsigned",
+		"Release.gpg":                "signature",
+	} {
+		got, err := os.ReadFile(filepath.Join(out, "dists", "stable-v1", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("expected %s to be %q, got %q", name, want, got)
+		}
+	}
+
+	// published suites can't be changed
+	published := storage.Local{Dir: out}
+	_, err = PublishSnapshot(ctx, published, filepath.Join(dir, "again"), "stable", "v1", "stable-v1", nil)
+	if err == nil {
+		t.Errorf("expected an error publishing a suite which already exists")
+	}
+}
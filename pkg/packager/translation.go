@@ -0,0 +1,38 @@
+package packager
+
+import (
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/packageset"
+	"github.com/common-fate/linuxpack/pkg/translation"
+)
+
+// prepareDescriptions sets the Description-md5 field of each package in the set.
+//
+// If translations is not nil, the long descriptions of packages are moved into
+// translations and only the synopsis is kept in the returned set. The keys of
+// the descriptions referenced by the set are recorded in used.
+func prepareDescriptions(set packageset.Set, translations *translation.Translation, used map[translation.Key]bool) packageset.Set {
+	var out packageset.Set
+
+	for _, pkg := range set.Packages {
+		// packages read from an index which has already been split
+		// only contain the synopsis, so their existing checksum is kept
+		if strings.Contains(pkg.Description, "\n") || pkg.DescriptionMD5 == "" {
+			pkg.DescriptionMD5 = translation.DescriptionMD5(pkg.Description)
+
+			if translations != nil {
+				translations.Add(pkg.Package, pkg.Description)
+			}
+		}
+
+		if translations != nil {
+			used[translation.Key{Package: pkg.Package, MD5: pkg.DescriptionMD5}] = true
+			pkg.Description = translation.Synopsis(pkg.Description)
+		}
+
+		out.Add(pkg)
+	}
+
+	return out
+}
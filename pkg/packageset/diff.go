@@ -0,0 +1,127 @@
+package packageset
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Change is a difference between two package sets.
+type Change struct {
+	// Action is added, removed or changed.
+	Action  string `json:"action"`
+	Package string `json:"package"`
+	Version string `json:"version"`
+	// Fields are the fields which differ between the two
+	// versions of a changed package.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a field which differs between two versions of a package.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Diff returns the packages which were added, removed or changed
+// between two sets, sorted by package and version. Packages are
+// matched by name and version.
+func Diff(from Set, to Set) []Change {
+	var changes []Change
+
+	for key, p := range to.Packages {
+		old, ok := from.Packages[key]
+		if !ok {
+			changes = append(changes, Change{Action: "added", Package: p.Package, Version: p.Version})
+			continue
+		}
+
+		fields := diffFields(old, p)
+		if len(fields) > 0 {
+			changes = append(changes, Change{Action: "changed", Package: p.Package, Version: p.Version, Fields: fields})
+		}
+	}
+
+	for key, p := range from.Packages {
+		if _, ok := to.Packages[key]; !ok {
+			changes = append(changes, Change{Action: "removed", Package: p.Package, Version: p.Version})
+		}
+	}
+
+	sortChanges(changes)
+	return changes
+}
+
+// sortChanges sorts changes by package name, then from oldest to newest version.
+func sortChanges(changes []Change) {
+	slices.SortFunc(changes, func(a, b Change) int {
+		if c := strings.Compare(a.Package, b.Package); c != 0 {
+			return c
+		}
+		return CompareVersions(a.Version, b.Version)
+	})
+}
+
+// diffFields returns the fields which differ between two packages.
+func diffFields(from Package, to Package) []FieldChange {
+	var changes []FieldChange
+
+	// extra fields may only be set on one of the packages,
+	// so fields are matched by name, and a missing field is empty
+	toFields := to.fields()
+	toValues := map[string]string{}
+	for _, f := range toFields {
+		toValues[f.key] = f.value
+	}
+
+	fromKeys := map[string]bool{}
+	for _, f := range from.fields() {
+		fromKeys[f.key] = true
+		if f.value != toValues[f.key] {
+			changes = append(changes, FieldChange{Field: f.key, From: f.value, To: toValues[f.key]})
+		}
+	}
+
+	for _, f := range toFields {
+		if !fromKeys[f.key] && f.value != "" {
+			changes = append(changes, FieldChange{Field: f.key, To: f.value})
+		}
+	}
+
+	return changes
+}
+
+// fields returns the fields of the package, in the order they are written.
+func (p Package) fields() []field {
+	fields := []field{
+		{key: "Package", value: p.Package},
+		{key: "Version", value: p.Version},
+		{key: "Licence", value: p.Licence},
+		{key: "Vendor", value: p.Vendor},
+		{key: "Architecture", value: p.Architecture},
+		{key: "Maintainer", value: p.Maintainer},
+		{key: "Installed-Size", value: p.InstalledSize},
+	}
+	fields = append(fields, p.relationships()...)
+	fields = append(fields, []field{
+		{key: "Section", value: p.Section},
+		{key: "Priority", value: p.Priority},
+		{key: "Homepage", value: p.Homepage},
+		{key: "Description", value: p.Description},
+		{key: "Description-md5", value: p.DescriptionMD5},
+	}...)
+
+	for _, f := range p.Extra {
+		fields = append(fields, field{key: f.Name, value: f.Value})
+	}
+
+	return append(fields, []field{
+		{key: "Filename", value: p.Filename},
+		{key: "MD5sum", value: p.MD5sum},
+		{key: "SHA1", value: p.SHA1},
+		{key: "SHA256", value: p.SHA256},
+		{key: "SHA512", value: p.SHA512},
+		{key: "Size", value: strconv.FormatInt(p.Size, 10)},
+	}...)
+}
@@ -17,14 +17,53 @@ type Package struct {
 	Architecture  string
 	Maintainer    string
 	InstalledSize string
+	PreDepends    string
 	Depends       string
+	Recommends    string
+	Suggests      string
+	Conflicts     string
+	Breaks        string
+	Replaces      string
+	Provides      string
+	Section       string
 	Priority      string
 	Homepage      string
 	Description   string
-	Filename      string
-	SHA1          string
-	SHA256        string
-	Size          int64
+	// DescriptionMD5 is the MD5 checksum of the full description,
+	// used to look up the description in Translation indexes.
+	DescriptionMD5 string
+	Filename       string
+	MD5sum         string
+	SHA1           string
+	SHA256         string
+	SHA512         string
+	Size           int64
+	// Extra are the package's other fields, such as fields set by
+	// other tools, which are written in order after the description.
+	// They are kept when an index is read and written, so that fields
+	// linuxpack doesn't handle aren't lost.
+	Extra []Field
+}
+
+// Field is a control field which doesn't have its own Package field.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// relationships returns the package relationship fields,
+// in the order they are written.
+func (p Package) relationships() []field {
+	return []field{
+		{key: "Pre-Depends", value: p.PreDepends},
+		{key: "Depends", value: p.Depends},
+		{key: "Recommends", value: p.Recommends},
+		{key: "Suggests", value: p.Suggests},
+		{key: "Conflicts", value: p.Conflicts},
+		{key: "Breaks", value: p.Breaks},
+		{key: "Replaces", value: p.Replaces},
+		{key: "Provides", value: p.Provides},
+	}
 }
 
 type packageKey struct {
@@ -48,6 +87,44 @@ func (s *Set) Add(p Package) {
 	s.Packages[key] = p
 }
 
+// Get returns the package with the given name and version, if it is in the set.
+func (s *Set) Get(name string, version string) (Package, bool) {
+	p, ok := s.Packages[packageKey{Package: name, Version: version}]
+	return p, ok
+}
+
+// Prune removes all but the newest keep versions of each package in the
+// set, returning the packages which were removed.
+func (s *Set) Prune(keep int) []Package {
+	// map of package name -> versions of the package
+	byName := map[string][]Package{}
+	for _, p := range s.Packages {
+		byName[p.Package] = append(byName[p.Package], p)
+	}
+
+	var removed []Package
+
+	for _, versions := range byName {
+		if len(versions) <= keep {
+			continue
+		}
+
+		// sort the versions from newest to oldest
+		slices.SortFunc(versions, func(a, b Package) int {
+			return CompareVersions(b.Version, a.Version)
+		})
+
+		for _, p := range versions[keep:] {
+			delete(s.Packages, packageKey{Package: p.Package, Version: p.Version})
+			removed = append(removed, p)
+		}
+	}
+
+	sortPackages(removed)
+
+	return removed
+}
+
 func (s *Set) Write(w io.Writer) error {
 	var packages []Package
 
@@ -57,7 +134,7 @@ func (s *Set) Write(w io.Writer) error {
 
 	sortPackages(packages)
 
-	for _, p := range s.Packages {
+	for _, p := range packages {
 		_, err := fmt.Fprintf(w, "Package: %s\n", p.Package)
 		if err != nil {
 			return err
@@ -92,16 +169,28 @@ func (s *Set) Write(w io.Writer) error {
 			return err
 		}
 
-		if p.Depends != "" {
-			_, err = fmt.Fprintf(w, "Depends: %s\n", p.Depends)
+		for _, f := range p.relationships() {
+			if f.value == "" {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "%s: %s\n", f.key, f.value)
 			if err != nil {
 				return err
 			}
 		}
 
-		_, err = fmt.Fprintf(w, "Priority: %s\n", p.Priority)
-		if err != nil {
-			return err
+		if p.Section != "" {
+			_, err = fmt.Fprintf(w, "Section: %s\n", p.Section)
+			if err != nil {
+				return err
+			}
+		}
+
+		if p.Priority != "" {
+			_, err = fmt.Fprintf(w, "Priority: %s\n", p.Priority)
+			if err != nil {
+				return err
+			}
 		}
 
 		_, err = fmt.Fprintf(w, "Homepage: %s\n", p.Homepage)
@@ -114,19 +203,51 @@ func (s *Set) Write(w io.Writer) error {
 			return err
 		}
 
+		if p.DescriptionMD5 != "" {
+			_, err = fmt.Fprintf(w, "Description-md5: %s\n", p.DescriptionMD5)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, f := range p.Extra {
+			_, err = fmt.Fprintf(w, "%s: %s\n", f.Name, f.Value)
+			if err != nil {
+				return err
+			}
+		}
+
 		_, err = fmt.Fprintf(w, "Filename: %s\n", p.Filename)
 		if err != nil {
 			return err
 		}
 
-		_, err = fmt.Fprintf(w, "SHA1: %s\n", p.SHA1)
-		if err != nil {
-			return err
+		if p.MD5sum != "" {
+			_, err = fmt.Fprintf(w, "MD5sum: %s\n", p.MD5sum)
+			if err != nil {
+				return err
+			}
 		}
 
-		_, err = fmt.Fprintf(w, "SHA256: %s\n", p.SHA256)
-		if err != nil {
-			return err
+		if p.SHA1 != "" {
+			_, err = fmt.Fprintf(w, "SHA1: %s\n", p.SHA1)
+			if err != nil {
+				return err
+			}
+		}
+
+		if p.SHA256 != "" {
+			_, err = fmt.Fprintf(w, "SHA256: %s\n", p.SHA256)
+			if err != nil {
+				return err
+			}
+		}
+
+		if p.SHA512 != "" {
+			_, err = fmt.Fprintf(w, "SHA512: %s\n", p.SHA512)
+			if err != nil {
+				return err
+			}
 		}
 
 		_, err = fmt.Fprintf(w, "Size: %v\n", p.Size)
@@ -175,23 +296,54 @@ func sortPackages(p []Package) {
 	})
 }
 
+// field is a single field in a control file paragraph.
+type field struct {
+	key   string
+	value string
+}
+
 func ReadSet(r io.Reader) (Set, error) {
 	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
 	var packages []Package
 
-	var currentPackage *Package
+	// the fields of the paragraph currently being read
+	var fields []field
+
+	flush := func() error {
+		if len(fields) == 0 {
+			return nil
+		}
+		p, err := parsePackage(fields)
+		if err != nil {
+			return err
+		}
+		packages = append(packages, p)
+		fields = nil
+		return nil
+	}
 
 	var lineNum int
 	for sc.Scan() {
 		lineNum++
 		line := sc.Text()
 
-		if line == "" && currentPackage != nil {
-			packages = append(packages, *currentPackage)
-			currentPackage = nil
+		if line == "" {
+			err := flush()
+			if err != nil {
+				return Set{}, err
+			}
+			continue
 		}
 
-		if line == "" {
+		// lines beginning with whitespace continue the previous field,
+		// such as the long description
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if len(fields) == 0 {
+				return Set{}, fmt.Errorf("invalid line %v: continuation line without a field: %q", lineNum, line)
+			}
+			fields[len(fields)-1].value += "\n" + line
 			continue
 		}
 
@@ -200,51 +352,95 @@ func ReadSet(r io.Reader) (Set, error) {
 			return Set{}, fmt.Errorf("invalid line %v: did not contain a \": \" separator: %q", lineNum, line)
 		}
 
-		if currentPackage == nil {
-			currentPackage = &Package{}
-		}
+		fields = append(fields, field{key: before, value: after})
+	}
 
-		switch before {
+	if err := sc.Err(); err != nil {
+		return Set{}, err
+	}
+
+	// the final paragraph may not be followed by a blank line
+	err := flush()
+	if err != nil {
+		return Set{}, err
+	}
+
+	var set Set
+
+	for _, p := range packages {
+		set.Add(p)
+	}
+
+	return set, nil
+}
+
+// parsePackage parses the fields of a paragraph into a Package.
+// Fields without their own Package field are kept in Extra.
+func parsePackage(fields []field) (Package, error) {
+	var p Package
+
+	for _, f := range fields {
+		switch f.key {
 		case "Package":
-			currentPackage.Package = after
+			p.Package = f.value
 		case "Version":
-			currentPackage.Version = after
+			p.Version = f.value
 		case "Licence":
-			currentPackage.Licence = after
+			p.Licence = f.value
 		case "Vendor":
-			currentPackage.Vendor = after
+			p.Vendor = f.value
 		case "Architecture":
-			currentPackage.Architecture = after
+			p.Architecture = f.value
 		case "Maintainer":
-			currentPackage.Maintainer = after
+			p.Maintainer = f.value
 		case "Installed-Size":
-			currentPackage.InstalledSize = after
+			p.InstalledSize = f.value
+		case "Pre-Depends":
+			p.PreDepends = f.value
+		case "Depends":
+			p.Depends = f.value
+		case "Recommends":
+			p.Recommends = f.value
+		case "Suggests":
+			p.Suggests = f.value
+		case "Conflicts":
+			p.Conflicts = f.value
+		case "Breaks":
+			p.Breaks = f.value
+		case "Replaces":
+			p.Replaces = f.value
+		case "Provides":
+			p.Provides = f.value
+		case "Section":
+			p.Section = f.value
 		case "Priority":
-			currentPackage.Priority = after
+			p.Priority = f.value
 		case "Homepage":
-			currentPackage.Homepage = after
+			p.Homepage = f.value
 		case "Description":
-			currentPackage.Description = after
+			p.Description = f.value
+		case "Description-md5":
+			p.DescriptionMD5 = f.value
 		case "Filename":
-			currentPackage.Filename = after
+			p.Filename = f.value
+		case "MD5sum":
+			p.MD5sum = f.value
 		case "SHA1":
-			currentPackage.SHA1 = after
+			p.SHA1 = f.value
 		case "SHA256":
-			currentPackage.SHA256 = after
+			p.SHA256 = f.value
+		case "SHA512":
+			p.SHA512 = f.value
 		case "Size":
-			sizeInt, err := strconv.ParseInt(after, 10, 0)
+			sizeInt, err := strconv.ParseInt(f.value, 10, 0)
 			if err != nil {
-				return Set{}, fmt.Errorf("error parsing size %q: %w", after, err)
+				return Package{}, fmt.Errorf("error parsing size %q: %w", f.value, err)
 			}
-			currentPackage.Size = sizeInt
+			p.Size = sizeInt
+		default:
+			p.Extra = append(p.Extra, Field{Name: f.key, Value: f.value})
 		}
 	}
 
-	var set Set
-
-	for _, p := range packages {
-		set.Add(p)
-	}
-
-	return set, nil
+	return p, nil
 }
@@ -23,12 +23,15 @@ Vendor: Common Fate
 Architecture: amd64
 Maintainer: Chris Norman <chris@commonfate.io>
 Installed-Size: 38697
+Section: utils
 Priority: optional
 Homepage: https://granted.dev
 Description: The easiest way to access your cloud.
 Filename: pool/amd64/stable/granted_0.27.5_linux_amd64.deb
+MD5sum: 5d41402abc4b2a76b9719d911017c592
 SHA1: ef07835809b153545ff323c2e903ae8647f5e849
 SHA256: b88d280e2e94085503aa739142b35d4618692b13163d5771b1ab6fb7286113e9
+SHA512: 9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043
 Size: 14326932
 
 `,
@@ -42,12 +45,15 @@ Size: 14326932
 						Architecture:  "amd64",
 						Maintainer:    "Chris Norman <chris@commonfate.io>",
 						InstalledSize: "38697",
+						Section:       "utils",
 						Priority:      "optional",
 						Homepage:      "https://granted.dev",
 						Description:   "The easiest way to access your cloud.",
 						Filename:      "pool/amd64/stable/granted_0.27.5_linux_amd64.deb",
+						MD5sum:        "5d41402abc4b2a76b9719d911017c592",
 						SHA1:          "ef07835809b153545ff323c2e903ae8647f5e849",
 						SHA256:        "b88d280e2e94085503aa739142b35d4618692b13163d5771b1ab6fb7286113e9",
+						SHA512:        "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
 						Size:          14326932,
 					},
 				},
@@ -126,6 +132,65 @@ Size: 14326932
 				},
 			},
 		},
+		{
+			name: "long_description",
+			input: `Package: granted
+Version: 0.27.5
+Architecture: amd64
+Description: The easiest way to access your cloud.
+ Granted is a command line interface tool which
+ simplifies access to cloud roles.
+ .
+ It supports multiple profiles.
+Description-md5: 2a2e2c4c5f5ef4b4c0f4c41d5a3b0d7e
+Size: 14326932`,
+			want: Set{
+				Packages: map[packageKey]Package{
+					{Package: "granted", Version: "0.27.5"}: {
+						Package:        "granted",
+						Version:        "0.27.5",
+						Architecture:   "amd64",
+						Description:    "The easiest way to access your cloud.\n Granted is a command line interface tool which\n simplifies access to cloud roles.\n .\n It supports multiple profiles.",
+						DescriptionMD5: "2a2e2c4c5f5ef4b4c0f4c41d5a3b0d7e",
+						Size:           14326932,
+					},
+				},
+			},
+		},
+		{
+			name: "extra_fields",
+			input: `Package: granted
+Version: 0.27.5
+Architecture: amd64
+Depends: libc6
+Multi-Arch: foreign
+Description: The easiest way to access your cloud.
+Built-Using: golang (= 1.22)
+ rust (= 1.77)
+Size: 14326932
+`,
+			want: Set{
+				Packages: map[packageKey]Package{
+					{Package: "granted", Version: "0.27.5"}: {
+						Package:      "granted",
+						Version:      "0.27.5",
+						Architecture: "amd64",
+						Depends:      "libc6",
+						Description:  "The easiest way to access your cloud.",
+						Size:         14326932,
+						Extra: []Field{
+							{Name: "Multi-Arch", Value: "foreign"},
+							{Name: "Built-Using", Value: "golang (= 1.22)\n rust (= 1.77)"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "continuation_without_field",
+			input:   " orphaned continuation line\n",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -141,3 +206,113 @@ Size: 14326932
 		})
 	}
 }
+
+func TestSet_Write(t *testing.T) {
+	var s Set
+	s.Add(Package{Package: "granted", Version: "0.27.5", Architecture: "amd64", Section: "utils", Priority: "optional", Size: 10})
+	s.Add(Package{Package: "granted-completions", Version: "0.27.5", Architecture: "all", Size: 10})
+
+	var b strings.Builder
+	err := s.Write(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Section and Priority are only written if they are set
+	want := `Package: granted
+Version: 0.27.5
+Licence: 
+Vendor: 
+Architecture: amd64
+Maintainer: 
+Installed-Size: 
+Section: utils
+Priority: optional
+Homepage: 
+Description: 
+Filename: 
+Size: 10
+
+Package: granted-completions
+Version: 0.27.5
+Licence: 
+Vendor: 
+Architecture: all
+Maintainer: 
+Installed-Size: 
+Homepage: 
+Description: 
+Filename: 
+Size: 10
+
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Write() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestSet_RoundTrip checks that fields which linuxpack doesn't
+// handle are kept when an index is read and written again.
+func TestSet_RoundTrip(t *testing.T) {
+	input := `Package: granted
+Version: 0.27.5
+Licence: MIT
+Vendor: Common Fate
+Architecture: amd64
+Maintainer: Common Fate <hello@commonfate.io>
+Installed-Size: 38697
+Depends: libc6
+Priority: optional
+Homepage: https://granted.dev
+Description: The easiest way to access your cloud.
+ Granted is a CLI.
+Multi-Arch: foreign
+Built-Using: golang (= 1.22)
+ rust (= 1.77)
+Bugs: https://github.com/common-fate/granted/issues
+Filename: pool/amd64/stable/granted_0.27.5_linux_amd64.deb
+SHA256: b88d280e2e94085503aa739142b35d4618692b13163d5771b1ab6fb7286113e9
+Size: 14326932
+
+`
+
+	set, err := ReadSet(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	err = set.Write(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(input, b.String()); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var from, to Set
+	from.Add(Package{Package: "assume", Version: "1.0", Size: 1})
+	from.Add(Package{Package: "granted", Version: "0.27.4", SHA256: "aaa", Size: 1})
+	to.Add(Package{Package: "granted", Version: "0.27.4", SHA256: "bbb", Size: 1})
+	to.Add(Package{Package: "granted", Version: "0.27.10", Size: 1})
+	from.Add(Package{Package: "granted", Version: "0.27.5", Size: 1, Extra: []Field{{Name: "Tag", Value: "a"}, {Name: "Bugs", Value: "b"}}})
+	to.Add(Package{Package: "granted", Version: "0.27.5", Size: 1, Extra: []Field{{Name: "Tag", Value: "c"}, {Name: "Multi-Arch", Value: "foreign"}}})
+
+	want := []Change{
+		{Action: "removed", Package: "assume", Version: "1.0"},
+		{Action: "changed", Package: "granted", Version: "0.27.4", Fields: []FieldChange{{Field: "SHA256", From: "aaa", To: "bbb"}}},
+		{Action: "changed", Package: "granted", Version: "0.27.5", Fields: []FieldChange{
+			{Field: "Tag", From: "a", To: "c"},
+			{Field: "Bugs", From: "b"},
+			{Field: "Multi-Arch", To: "foreign"},
+		}},
+		{Action: "added", Package: "granted", Version: "0.27.10"},
+	}
+
+	if diff := cmp.Diff(want, Diff(from, to)); diff != "" {
+		t.Errorf("Diff() mismatch (-want +got):\n%s", diff)
+	}
+}
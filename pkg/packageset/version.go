@@ -0,0 +1,109 @@
+package packageset
+
+import "strings"
+
+// CompareVersions compares two Debian package versions, returning a
+// negative number if a is older than b, zero if they are equal, or a
+// positive number if a is newer than b. Versions are compared using the
+// algorithm described in deb-version(7).
+func CompareVersions(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitVersion(a)
+	bEpoch, bUpstream, bRevision := splitVersion(b)
+
+	if c := compareNumeric(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	if c := compareVersionPart(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return compareVersionPart(aRevision, bRevision)
+}
+
+// splitVersion splits a version into its epoch, upstream version and revision.
+func splitVersion(v string) (epoch string, upstream string, revision string) {
+	epoch = "0"
+	if before, after, found := strings.Cut(v, ":"); found {
+		epoch, v = before, after
+	}
+
+	upstream = v
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		upstream, revision = v[:i], v[i+1:]
+	}
+
+	return epoch, upstream, revision
+}
+
+// compareVersionPart compares an upstream version or revision, alternating
+// between comparing non-digit and digit runs.
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		var aText, bText string
+		aText, a = splitRun(a, false)
+		bText, b = splitRun(b, false)
+		if c := compareText(aText, bText); c != 0 {
+			return c
+		}
+
+		var aNum, bNum string
+		aNum, a = splitRun(a, true)
+		bNum, b = splitRun(b, true)
+		if c := compareNumeric(aNum, bNum); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// splitRun splits the leading run of digits, or non-digits, from s.
+func splitRun(s string, digits bool) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareText compares non-digit runs, where letters sort before
+// non-letters and a tilde sorts before anything, even the end of the run.
+func compareText(a, b string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var ac, bc int
+		if i < len(a) {
+			ac = order(a[i])
+		}
+		if i < len(b) {
+			bc = order(b[i])
+		}
+		if ac != bc {
+			return ac - bc
+		}
+	}
+	return 0
+}
+
+// order returns the sort weight of a character in a non-digit run.
+func order(c byte) int {
+	switch {
+	case c == '~':
+		return -1
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	}
+	return int(c) + 256
+}
+
+// compareNumeric compares digit runs by their numeric value,
+// treating an empty run as zero.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
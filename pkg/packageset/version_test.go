@@ -0,0 +1,65 @@
+package packageset
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.27.5", "0.27.5", 0},
+		{"0.27.5", "0.27.10", -1},
+		{"1:0.1", "0.27.5", 1},
+		{"0.27.5-1", "0.27.5-2", -1},
+		{"0.27.5~rc1", "0.27.5", -1},
+		{"0.27.5+dfsg", "0.27.5", 1},
+		{"0.27.5a", "0.27.5+", -1},
+		{"1.01", "1.1", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			got := CompareVersions(tt.a, tt.b)
+			if sign(got) != tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_Prune(t *testing.T) {
+	var s Set
+	for _, v := range []string{"0.27.4", "0.27.10", "0.27.5", "1:0.1"} {
+		s.Add(Package{Package: "granted", Version: v})
+	}
+	s.Add(Package{Package: "assume", Version: "1.0"})
+
+	removed := s.Prune(2)
+
+	var got []string
+	for _, p := range removed {
+		got = append(got, p.Version)
+	}
+	if len(got) != 2 || got[0] != "0.27.4" || got[1] != "0.27.5" {
+		t.Errorf("unexpected removed versions %v", got)
+	}
+
+	for _, v := range []string{"1:0.1", "0.27.10"} {
+		if _, ok := s.Packages[packageKey{Package: "granted", Version: v}]; !ok {
+			t.Errorf("expected version %s to be kept", v)
+		}
+	}
+	if len(s.Packages) != 3 {
+		t.Errorf("expected 3 packages to remain, got %d", len(s.Packages))
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
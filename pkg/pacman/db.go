@@ -0,0 +1,273 @@
+package pacman
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/compression"
+)
+
+// Database is a pacman sync database, as maintained by repo-add.
+// As with repo-add, only a single version of each package is kept.
+type Database struct {
+	// Entries maps a package name to its database entry.
+	Entries map[string]Entry
+}
+
+// Entry is a package's files in a sync database. The desc and files
+// contents are kept as-is so that fields which linuxpack doesn't understand
+// are preserved when the database is rewritten.
+type Entry struct {
+	Name    string
+	Version string
+	// Desc is the contents of the package's desc file.
+	Desc []byte
+	// Files is the contents of the package's files file,
+	// which is only included in the .files database.
+	Files []byte
+}
+
+// dir returns the directory of the entry in the database archive.
+func (e Entry) dir() string {
+	return e.Name + "-" + e.Version
+}
+
+// Add adds a package to the database, replacing any existing version of the
+// package. filename is the name of the package file in the repository, size
+// is its size, and signature is its detached PGP signature, if signed.
+func (db *Database) Add(p *Package, filename string, size int64, sums checksum.Sums, signature []byte) {
+	var desc bytes.Buffer
+
+	section := func(name string, values ...string) {
+		var nonEmpty []string
+		for _, v := range values {
+			if v != "" {
+				nonEmpty = append(nonEmpty, v)
+			}
+		}
+		if len(nonEmpty) == 0 {
+			return
+		}
+		fmt.Fprintf(&desc, "%%%s%%\n%s\n\n", name, strings.Join(nonEmpty, "\n"))
+	}
+
+	section("FILENAME", filename)
+	section("NAME", p.Name)
+	section("BASE", p.Base)
+	section("VERSION", p.Version)
+	section("DESC", p.Description)
+	section("GROUPS", p.Groups...)
+	section("CSIZE", strconv.FormatInt(size, 10))
+	section("ISIZE", strconv.FormatInt(p.InstalledSize, 10))
+	section("MD5SUM", sums[checksum.MD5])
+	section("SHA256SUM", sums[checksum.SHA256])
+	if len(signature) > 0 {
+		section("PGPSIG", base64.StdEncoding.EncodeToString(signature))
+	}
+	section("URL", p.URL)
+	section("LICENSE", p.Licenses...)
+	section("ARCH", p.Arch)
+	section("BUILDDATE", strconv.FormatInt(p.BuildDate, 10))
+	section("PACKAGER", p.Packager)
+	section("REPLACES", p.Replaces...)
+	section("CONFLICTS", p.Conflicts...)
+	section("PROVIDES", p.Provides...)
+	section("DEPENDS", p.Depends...)
+	section("OPTDEPENDS", p.OptDepends...)
+	section("MAKEDEPENDS", p.MakeDepends...)
+	section("CHECKDEPENDS", p.CheckDepends...)
+
+	var files bytes.Buffer
+	files.WriteString("%FILES%\n")
+	for _, f := range p.Files {
+		files.WriteString(f + "\n")
+	}
+	files.WriteString("\n")
+
+	db.add(Entry{
+		Name:    p.Name,
+		Version: p.Version,
+		Desc:    desc.Bytes(),
+		Files:   files.Bytes(),
+	})
+}
+
+func (db *Database) add(e Entry) {
+	if db.Entries == nil {
+		db.Entries = map[string]Entry{}
+	}
+	db.Entries[e.Name] = e
+}
+
+// ReadArchive reads a sync database archive, such as granted.files.tar.gz.
+// The compression format of the archive is detected automatically.
+func ReadArchive(r io.Reader) (Database, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(6)
+	if err != nil && err != io.EOF {
+		return Database{}, err
+	}
+
+	dr, err := compression.Detect(magic).NewReader(br)
+	if err != nil {
+		return Database{}, err
+	}
+	defer dr.Close()
+
+	// map of entry directory -> entry
+	entries := map[string]*Entry{}
+
+	tr := tar.NewReader(dr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Database{}, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dir, file := path.Split(strings.TrimPrefix(hdr.Name, "./"))
+		dir = strings.TrimSuffix(dir, "/")
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return Database{}, err
+		}
+
+		e, ok := entries[dir]
+		if !ok {
+			e = &Entry{}
+			entries[dir] = e
+		}
+
+		switch file {
+		case "desc":
+			e.Desc = data
+			sections := parseSections(data)
+			e.Name = first(sections["NAME"])
+			e.Version = first(sections["VERSION"])
+		case "files":
+			e.Files = data
+		}
+	}
+
+	var db Database
+	for dir, e := range entries {
+		if e.Desc == nil {
+			return Database{}, fmt.Errorf("invalid database: %s has no desc file", dir)
+		}
+		db.add(*e)
+	}
+
+	return db, nil
+}
+
+// WriteArchive writes the database as a gzipped tar archive. If withFiles is
+// true, the package file lists are included, as in the .files database.
+func (db *Database) WriteArchive(w io.Writer, withFiles bool, modTime time.Time) error {
+	var names []string
+	for name := range db.Entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	gz, err := compression.Gzip.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	for _, name := range names {
+		e := db.Entries[name]
+
+		err = tw.WriteHeader(&tar.Header{
+			Name:     e.dir() + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+			ModTime:  modTime,
+			Format:   tar.FormatUSTAR,
+		})
+		if err != nil {
+			return err
+		}
+
+		files := []archiveFile{{name: "desc", data: e.Desc}}
+		if withFiles && e.Files != nil {
+			files = append(files, archiveFile{name: "files", data: e.Files})
+		}
+
+		for _, f := range files {
+			err = tw.WriteHeader(&tar.Header{
+				Name:    e.dir() + "/" + f.name,
+				Mode:    0644,
+				Size:    int64(len(f.data)),
+				ModTime: modTime,
+				Format:  tar.FormatUSTAR,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := tw.Write(f.data); err != nil {
+				return err
+			}
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// archiveFile is a file in an entry's directory in the database archive.
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// parseSections parses the %SECTION% blocks of a desc file.
+func parseSections(data []byte) map[string][]string {
+	sections := map[string][]string{}
+
+	var current string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%") && len(line) > 1 {
+			current = strings.Trim(line, "%")
+			continue
+		}
+		if line == "" {
+			current = ""
+			continue
+		}
+		if current != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+
+	return sections
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
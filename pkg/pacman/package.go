@@ -0,0 +1,171 @@
+package pacman
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/compression"
+)
+
+// Package is the metadata read from a pacman package, such as a .pkg.tar.zst.
+type Package struct {
+	Name          string
+	Base          string
+	Version       string
+	Description   string
+	URL           string
+	BuildDate     int64
+	Packager      string
+	InstalledSize int64
+	Arch          string
+	Licenses      []string
+	Groups        []string
+	Depends       []string
+	OptDepends    []string
+	MakeDepends   []string
+	CheckDepends  []string
+	Provides      []string
+	Conflicts     []string
+	Replaces      []string
+	Backup        []string
+	// Files are the paths installed by the package, relative to the
+	// filesystem root. Directories have a trailing slash.
+	Files []string
+}
+
+// Read reads a pacman package from r. The compression format of the
+// package is detected automatically.
+func Read(r io.Reader) (*Package, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading package: %w", err)
+	}
+
+	dr, err := compression.Detect(magic).NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing package: %w", err)
+	}
+	defer dr.Close()
+
+	var p *Package
+	var files []string
+
+	tr := tar.NewReader(dr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading package: %w", err)
+		}
+
+		name := strings.TrimPrefix(hdr.Name, "./")
+
+		if name == ".PKGINFO" {
+			p, err = parsePKGINFO(tr)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// metadata files such as .BUILDINFO and .MTREE aren't installed
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeDir && !strings.HasSuffix(name, "/") {
+			name += "/"
+		}
+		files = append(files, name)
+	}
+
+	if p == nil {
+		return nil, errors.New("invalid pacman package: no .PKGINFO")
+	}
+	p.Files = files
+
+	return p, nil
+}
+
+func parsePKGINFO(r io.Reader) (*Package, error) {
+	var p Package
+
+	sc := bufio.NewScanner(r)
+	var lineNum int
+	for sc.Scan() {
+		lineNum++
+		line := sc.Text()
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, " = ")
+		if !found {
+			return nil, fmt.Errorf("invalid .PKGINFO line %v: did not contain a \" = \" separator: %q", lineNum, line)
+		}
+
+		switch key {
+		case "pkgname":
+			p.Name = value
+		case "pkgbase":
+			p.Base = value
+		case "pkgver":
+			p.Version = value
+		case "pkgdesc":
+			p.Description = value
+		case "url":
+			p.URL = value
+		case "builddate":
+			p.BuildDate, _ = strconv.ParseInt(value, 10, 64)
+		case "packager":
+			p.Packager = value
+		case "size":
+			p.InstalledSize, _ = strconv.ParseInt(value, 10, 64)
+		case "arch":
+			p.Arch = value
+		case "license":
+			p.Licenses = append(p.Licenses, value)
+		case "group":
+			p.Groups = append(p.Groups, value)
+		case "depend":
+			p.Depends = append(p.Depends, value)
+		case "optdepend":
+			p.OptDepends = append(p.OptDepends, value)
+		case "makedepend":
+			p.MakeDepends = append(p.MakeDepends, value)
+		case "checkdepend":
+			p.CheckDepends = append(p.CheckDepends, value)
+		case "provides":
+			p.Provides = append(p.Provides, value)
+		case "conflict":
+			p.Conflicts = append(p.Conflicts, value)
+		case "replaces":
+			p.Replaces = append(p.Replaces, value)
+		case "backup":
+			p.Backup = append(p.Backup, value)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if p.Name == "" || p.Version == "" || p.Arch == "" {
+		return nil, errors.New("invalid .PKGINFO: missing pkgname, pkgver or arch")
+	}
+	if p.Base == "" {
+		p.Base = p.Name
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,297 @@
+package pacman
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/plan"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// Packager publishes pacman packages to an Arch Linux repository.
+//
+// Each channel is a separate repository, stored under archlinux/<channel>/,
+// with a directory per architecture containing the packages and sync databases.
+// Clients use a server of the form https://example.com/archlinux/<channel>/$arch.
+type Packager struct {
+	// Storage holds the published repository, which
+	// the new packages are merged with.
+	Storage      storage.Storage
+	OutputFolder string
+	Channel      string
+	// RepoName is the name of the repository, which must match the section
+	// name in pacman.conf. Defaults to the channel.
+	RepoName string
+	// Architectures are the architectures that packages built for
+	// "any" architecture are published to.
+	Architectures []string
+	Files         []string
+	// Signer signs the packages and databases, if set.
+	Signer signing.Signer
+	// DryRun skips copying packages into the output folder and signing,
+	// so that changes can be planned without publishing them.
+	DryRun bool
+	// Plan records the changes made to the repository, if set.
+	Plan *plan.Plan
+}
+
+// DefaultArchitectures are the architectures which "any" packages
+// are published to if none are configured.
+var DefaultArchitectures = []string{"x86_64", "aarch64"}
+
+// addedPackage is a package which has been copied into the output folder.
+type addedPackage struct {
+	pkg      *Package
+	filename string
+	size     int64
+	sums     checksum.Sums
+	// targets are the architectures the package is added to.
+	targets   []string
+	signature []byte
+}
+
+func (p Packager) Package(ctx context.Context) error {
+	repoName := p.RepoName
+	if repoName == "" {
+		repoName = p.Channel
+	}
+
+	architectures := p.Architectures
+	if len(architectures) == 0 {
+		architectures = DefaultArchitectures
+	}
+
+	repoPath := path.Join("archlinux", p.Channel)
+	outPath := filepath.Join(p.OutputFolder, "archlinux", p.Channel)
+	var err error
+
+	// map of architecture -> database
+	dbs := map[string]Database{}
+
+	for _, fileName := range p.Files {
+		added, err := p.readFile(fileName, outPath, architectures)
+		if err != nil {
+			return err
+		}
+
+		if !p.DryRun {
+			err = p.signFile(ctx, outPath, added)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, arch := range added.targets {
+			p.Plan.AddUpload(path.Join(repoPath, arch, added.filename), added.size)
+
+			db, ok := dbs[arch]
+			if !ok {
+				// only the databases for architectures which have new
+				// packages are read, as the others are left unchanged
+				db, err = p.readExistingDatabase(ctx, path.Join(repoPath, arch, repoName+".files.tar.gz"))
+				if err != nil {
+					return err
+				}
+			}
+
+			change := plan.PackageChange{
+				Action:  plan.Added,
+				Format:  "pacman",
+				Index:   path.Join(repoPath, arch, repoName+".db"),
+				Name:    added.pkg.Name,
+				Version: added.pkg.Version,
+				Arch:    arch,
+			}
+			if existing, ok := db.Entries[added.pkg.Name]; ok {
+				change.Action = plan.Replaced
+
+				// only one version of each package is kept,
+				// so older package files are no longer referenced
+				old := first(parseSections(existing.Desc)["FILENAME"])
+				if old != "" && old != added.filename {
+					p.Plan.AddDelete(path.Join(repoPath, arch, old))
+					p.Plan.AddDelete(path.Join(repoPath, arch, old+".sig"))
+				}
+			}
+			p.Plan.AddPackage(change)
+
+			db.Add(added.pkg, added.filename, added.size, added.sums, added.signature)
+			dbs[arch] = db
+		}
+	}
+
+	var archs []string
+	for arch := range dbs {
+		archs = append(archs, arch)
+	}
+	slices.Sort(archs)
+
+	now := time.Now().UTC()
+
+	for _, arch := range archs {
+		db := dbs[arch]
+		archPath := filepath.Join(outPath, arch)
+
+		err = os.MkdirAll(archPath, 0755)
+		if err != nil {
+			return err
+		}
+
+		for _, withFiles := range []bool{false, true} {
+			kind := "db"
+			if withFiles {
+				kind = "files"
+			}
+
+			var buf bytes.Buffer
+			err = db.WriteArchive(&buf, withFiles, now)
+			if err != nil {
+				return err
+			}
+
+			// pacman fetches <repo>.db, which repo-add creates as a symlink
+			// to <repo>.db.tar.gz. Object storage doesn't support symlinks,
+			// so both are written.
+			for _, name := range []string{repoName + "." + kind, repoName + "." + kind + ".tar.gz"} {
+				dbPath := filepath.Join(archPath, name)
+				err = os.WriteFile(dbPath, buf.Bytes(), 0644)
+				if err != nil {
+					return err
+				}
+
+				if p.Signer != nil && !p.DryRun {
+					err = p.Signer.DetachSign(ctx, dbPath, dbPath+".sig", false)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// readFile reads a pacman package, computing its checksums and copying
+// it into the directory of each architecture it is added to in the same
+// pass. The architecture is only known once the package is read, so the
+// file is copied to a temporary file which is renamed once it is read.
+func (p Packager) readFile(fileName string, outPath string, architectures []string) (*addedPackage, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := checksum.NewHasher(checksum.MD5, checksum.SHA256)
+	w := io.Writer(hasher)
+
+	var tmp *output.TempFile
+	if !p.DryRun {
+		tmp, err = output.CreateTemp(outPath, fileInfo.Name())
+		if err != nil {
+			return nil, err
+		}
+		defer tmp.Cleanup()
+
+		w = io.MultiWriter(hasher, tmp)
+	}
+
+	pkg, err := Read(io.TeeReader(file, w))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	added := &addedPackage{
+		pkg:      pkg,
+		filename: fileInfo.Name(),
+		size:     fileInfo.Size(),
+		sums:     hasher.Sums(),
+		targets:  []string{pkg.Arch},
+	}
+	if pkg.Arch == "any" {
+		added.targets = architectures
+	}
+
+	if !p.DryRun {
+		var paths []string
+		for _, arch := range added.targets {
+			pathToCopy := filepath.Join(outPath, arch, added.filename)
+			fmt.Printf("adding %s %s as %s\n", pkg.Name, pkg.Version, pathToCopy)
+			paths = append(paths, pathToCopy)
+		}
+
+		err = tmp.Commit(paths...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return added, nil
+}
+
+// signFile writes the signature of a package next to each copy of it,
+// signing it if a signer is configured.
+func (p Packager) signFile(ctx context.Context, outPath string, added *addedPackage) error {
+	for _, arch := range added.targets {
+		pathToCopy := filepath.Join(outPath, arch, added.filename)
+
+		if p.Signer != nil && added.signature == nil {
+			sigPath := pathToCopy + ".sig"
+			err := p.Signer.DetachSign(ctx, pathToCopy, sigPath, false)
+			if err != nil {
+				return err
+			}
+			added.signature, err = os.ReadFile(sigPath)
+			if err != nil {
+				return err
+			}
+		} else if added.signature != nil {
+			err := os.WriteFile(pathToCopy+".sig", added.signature, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readExistingDatabase reads an existing sync database from storage.
+func (p Packager) readExistingDatabase(ctx context.Context, key string) (Database, error) {
+	fmt.Printf("reading existing database from %s\n", p.Storage.URL(key))
+
+	body, err := p.Storage.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		fmt.Printf("no database found\n")
+		return Database{}, nil
+	}
+	if err != nil {
+		return Database{}, err
+	}
+	defer body.Close()
+
+	db, err := ReadArchive(body)
+	if err != nil {
+		return Database{}, fmt.Errorf("error reading %s: %w", key, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,149 @@
+package pacman
+
+import (
+	"archive/tar"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+)
+
+func testPackage(t *testing.T) []byte {
+	t.Helper()
+
+	pkginfo := `# Generated by makepkg
+pkgname = granted
+pkgbase = granted
+pkgver = 0.27.5-1
+pkgdesc = The easiest way to access your cloud.
+url = https://granted.dev
+builddate = 1700000000
+packager = Common Fate <hello@commonfate.io>
+size = 38697
+arch = x86_64
+license = MIT
+depend = glibc
+provides = assume
+`
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+
+	entries := []struct {
+		name string
+		dir  bool
+		data string
+	}{
+		{name: ".PKGINFO", data: pkginfo},
+		{name: ".MTREE", data: "mtree"},
+		{name: "usr", dir: true},
+		{name: "usr/bin", dir: true},
+		{name: "usr/bin/granted", data: "#!/bin/sh\n"},
+	}
+
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Format: tar.FormatUSTAR}
+		if e.dir {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	got, err := Read(bytes.NewReader(testPackage(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Package{
+		Name:          "granted",
+		Base:          "granted",
+		Version:       "0.27.5-1",
+		Description:   "The easiest way to access your cloud.",
+		URL:           "https://granted.dev",
+		BuildDate:     1700000000,
+		Packager:      "Common Fate <hello@commonfate.io>",
+		InstalledSize: 38697,
+		Arch:          "x86_64",
+		Licenses:      []string{"MIT"},
+		Depends:       []string{"glibc"},
+		Provides:      []string{"assume"},
+		Files:         []string{"usr/", "usr/bin/", "usr/bin/granted"},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDatabase_Archive(t *testing.T) {
+	pkg, err := Read(bytes.NewReader(testPackage(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var db Database
+	db.add(Entry{
+		Name:    "granted",
+		Version: "0.27.4-1",
+		Desc:    []byte("%NAME%\ngranted\n\n%VERSION%\n0.27.4-1\n\n"),
+	})
+	db.add(Entry{
+		Name:    "assume",
+		Version: "1.0-1",
+		Desc:    []byte("%NAME%\nassume\n\n%VERSION%\n1.0-1\n\n%XDATA%\npkgtype=pkg\n\n"),
+		Files:   []byte("%FILES%\nusr/bin/assume\n\n"),
+	})
+
+	// adding a new version replaces the existing entry
+	db.Add(pkg, "granted-0.27.5-1-x86_64.pkg.tar.zst", 1234, checksum.Sums{checksum.MD5: "abc", checksum.SHA256: "def"}, []byte("sig"))
+
+	var archive bytes.Buffer
+	if err := db.WriteArchive(&archive, true, time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadArchive(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(db, got); diff != "" {
+		t.Errorf("ReadArchive() mismatch (-want +got):\n%s", diff)
+	}
+
+	desc := string(got.Entries["granted"].Desc)
+	for _, want := range []string{
+		"%FILENAME%\ngranted-0.27.5-1-x86_64.pkg.tar.zst\n",
+		"%VERSION%\n0.27.5-1\n",
+		"%CSIZE%\n1234\n",
+		"%SHA256SUM%\ndef\n",
+		"%PGPSIG%\nc2ln\n",
+	} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("expected desc to contain %q, got:\n%s", want, desc)
+		}
+	}
+}
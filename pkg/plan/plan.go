@@ -0,0 +1,311 @@
+// Package plan records the changes which publishing packages makes to a
+// repository, so that they can be reviewed before anything is uploaded.
+package plan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// Action is a change made to a package or object.
+type Action string
+
+const (
+	Added     Action = "added"
+	Replaced  Action = "replaced"
+	Removed   Action = "removed"
+	Created   Action = "created"
+	Updated   Action = "updated"
+	Unchanged Action = "unchanged"
+	Deleted   Action = "deleted"
+)
+
+// Plan is the set of changes made to a repository.
+//
+// The methods which record changes do nothing if the plan is nil,
+// so packagers can record changes without checking whether a plan
+// was requested.
+type Plan struct {
+	// Packages are the packages added to, replaced in, or removed from indexes.
+	Packages []PackageChange `json:"packages"`
+	// Uploads are the package files to upload.
+	Uploads []Object `json:"uploads"`
+	// Indexes are the changes to the repository metadata files.
+	Indexes []IndexChange `json:"indexes"`
+	// Deletes are the keys of objects which are no longer
+	// referenced by the repository and can be deleted.
+	Deletes []string `json:"deletes"`
+}
+
+// New returns an empty plan.
+func New() *Plan {
+	// the lists are empty rather than nil so that
+	// they are written as empty arrays in JSON
+	return &Plan{
+		Packages: []PackageChange{},
+		Uploads:  []Object{},
+		Indexes:  []IndexChange{},
+		Deletes:  []string{},
+	}
+}
+
+// PackageChange is a package added to, replaced in, or removed from an index.
+type PackageChange struct {
+	Action Action `json:"action"`
+	// Format is the package format, such as deb or rpm.
+	Format string `json:"format"`
+	// Index is the key of the index listing the package,
+	// such as dists/stable/main/binary-amd64/Packages.
+	Index   string `json:"index"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+}
+
+// Object is an object to upload.
+type Object struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// IndexChange is a change to a repository metadata file.
+type IndexChange struct {
+	Action    Action `json:"action"`
+	Key       string `json:"key"`
+	OldSize   int64  `json:"old_size,omitempty"`
+	OldSHA256 string `json:"old_sha256,omitempty"`
+	NewSize   int64  `json:"new_size,omitempty"`
+	NewSHA256 string `json:"new_sha256,omitempty"`
+}
+
+// AddPackage records a change to a package.
+func (p *Plan) AddPackage(c PackageChange) {
+	if p == nil {
+		return
+	}
+	p.Packages = append(p.Packages, c)
+}
+
+// AddUpload records a package file to upload.
+func (p *Plan) AddUpload(key string, size int64) {
+	if p == nil {
+		return
+	}
+	p.Uploads = append(p.Uploads, Object{Key: key, Size: size})
+}
+
+// AddDelete records an object which can be deleted.
+func (p *Plan) AddDelete(key string) {
+	if p == nil || slices.Contains(p.Deletes, key) {
+		return
+	}
+	p.Deletes = append(p.Deletes, key)
+}
+
+// DiffIndexes compares the metadata files written to dir with the objects
+// in storage, recording the index changes. dir must only contain the
+// metadata written in a dry run, as package files are not copied.
+func (p *Plan) DiffIndexes(ctx context.Context, store storage.Storage, dir string) error {
+	written := map[string]bool{}
+
+	err := filepath.WalkDir(dir, func(fileName string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, fileName)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		// the marker file isn't part of the repository, and isn't synced
+		if key == output.MarkerFile {
+			return nil
+		}
+		written[key] = true
+
+		change := IndexChange{Key: key}
+
+		change.NewSHA256, change.NewSize, err = hashFile(fileName)
+		if err != nil {
+			return err
+		}
+
+		change.OldSHA256, change.OldSize, err = hashObject(ctx, store, key)
+		switch {
+		case err == storage.ErrNotFound:
+			change.Action = Created
+		case err != nil:
+			return err
+		case change.OldSHA256 == change.NewSHA256:
+			change.Action = Unchanged
+		default:
+			change.Action = Updated
+		}
+
+		p.Indexes = append(p.Indexes, change)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// indexes in compression formats which are no longer
+	// written are left stale in storage, so are deleted
+	var keys []string
+	for key := range written {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	checked := map[string]bool{}
+	for _, key := range keys {
+		base := strings.TrimSuffix(key, compression.FromExt(key).Ext())
+		if checked[base] || !isCompressedIndex(base) {
+			continue
+		}
+		checked[base] = true
+
+		for _, f := range compression.All {
+			sibling := base + f.Ext()
+			if written[sibling] {
+				continue
+			}
+
+			sum, size, err := hashObject(ctx, store, sibling)
+			if err == storage.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			p.Indexes = append(p.Indexes, IndexChange{Action: Deleted, Key: sibling, OldSize: size, OldSHA256: sum})
+			p.AddDelete(sibling)
+		}
+	}
+
+	slices.SortFunc(p.Indexes, func(a, b IndexChange) int { return strings.Compare(a.Key, b.Key) })
+
+	return nil
+}
+
+// isCompressedIndex returns true if an index, without its compression
+// extension, is written in the configurable compression formats.
+func isCompressedIndex(key string) bool {
+	name := path.Base(key)
+	return name == "Packages" || strings.HasPrefix(name, "Translation-")
+}
+
+func hashFile(fileName string) (string, int64, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	return hashReader(f)
+}
+
+func hashObject(ctx context.Context, store storage.Storage, key string) (string, int64, error) {
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer body.Close()
+
+	return hashReader(body)
+}
+
+func hashReader(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Write writes the plan in a human-readable format.
+func (p *Plan) Write(w io.Writer) error {
+	var b strings.Builder
+
+	symbols := map[Action]string{
+		Added:     "+",
+		Created:   "+",
+		Replaced:  "~",
+		Updated:   "~",
+		Removed:   "-",
+		Deleted:   "-",
+		Unchanged: "=",
+	}
+
+	b.WriteString("Packages:\n")
+	if len(p.Packages) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, c := range p.Packages {
+		fmt.Fprintf(&b, "  %s %s %s (%s) %s in %s\n", symbols[c.Action], c.Name, c.Version, c.Arch, c.Action, c.Index)
+	}
+
+	b.WriteString("\nUploads:\n")
+	if len(p.Uploads) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, o := range p.Uploads {
+		fmt.Fprintf(&b, "  + %s (%d bytes)\n", o.Key, o.Size)
+	}
+
+	b.WriteString("\nIndexes:\n")
+	var unchanged int
+	for _, c := range p.Indexes {
+		switch c.Action {
+		case Unchanged:
+			unchanged++
+		case Created:
+			fmt.Fprintf(&b, "  + %s (sha256 %s, %d bytes)\n", c.Key, short(c.NewSHA256), c.NewSize)
+		case Deleted:
+			fmt.Fprintf(&b, "  - %s (sha256 %s, %d bytes)\n", c.Key, short(c.OldSHA256), c.OldSize)
+		default:
+			fmt.Fprintf(&b, "  ~ %s (sha256 %s -> %s, %d -> %d bytes)\n", c.Key, short(c.OldSHA256), short(c.NewSHA256), c.OldSize, c.NewSize)
+		}
+	}
+	if unchanged > 0 {
+		fmt.Fprintf(&b, "  = %d unchanged\n", unchanged)
+	}
+	if len(p.Indexes) == 0 {
+		b.WriteString("  (none)\n")
+	}
+
+	b.WriteString("\nDeletes:\n")
+	if len(p.Deletes) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, key := range p.Deletes {
+		fmt.Fprintf(&b, "  - %s\n", key)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// short abbreviates a checksum for display.
+func short(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
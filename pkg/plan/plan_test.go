@@ -0,0 +1,95 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+// memStorage is an in-memory storage.Storage for tests.
+type memStorage map[string][]byte
+
+func (m memStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m memStorage) URL(key string) string {
+	return "mem://" + key
+}
+
+func TestPlan_DiffIndexes(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"dists/stable/Release":                       "new release",
+		"dists/stable/main/binary-amd64/Packages":    "packages",
+		"dists/stable/main/binary-amd64/Packages.gz": "packages.gz",
+		output.MarkerFile:                            "",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := memStorage{
+		"dists/stable/Release":                        []byte("old release"),
+		"dists/stable/main/binary-amd64/Packages":     []byte("packages"),
+		"dists/stable/main/binary-amd64/Packages.bz2": []byte("stale"),
+	}
+
+	pl := New()
+	err := pl.DiffIndexes(context.Background(), store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range pl.Indexes {
+		got = append(got, string(c.Action)+" "+c.Key)
+	}
+	want := []string{
+		"updated dists/stable/Release",
+		"unchanged dists/stable/main/binary-amd64/Packages",
+		"deleted dists/stable/main/binary-amd64/Packages.bz2",
+		"created dists/stable/main/binary-amd64/Packages.gz",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DiffIndexes() mismatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"dists/stable/main/binary-amd64/Packages.bz2"}, pl.Deletes); diff != "" {
+		t.Errorf("deletes mismatch (-want +got):\n%s", diff)
+	}
+
+	var buf bytes.Buffer
+	if err := pl.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "  = 1 unchanged\n") {
+		t.Errorf("expected unchanged indexes to be summarised, got:\n%s", buf.String())
+	}
+}
+
+func TestPlan_NilIsNoop(t *testing.T) {
+	var pl *Plan
+	pl.AddPackage(PackageChange{Name: "granted"})
+	pl.AddUpload("pool/granted.deb", 1)
+	pl.AddDelete("pool/granted.deb")
+}
@@ -0,0 +1,243 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	leadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	headerMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+const leadSize = 96
+
+// header tag types
+const (
+	typeNull        = 0
+	typeChar        = 1
+	typeInt8        = 2
+	typeInt16       = 3
+	typeInt32       = 4
+	typeInt64       = 5
+	typeString      = 6
+	typeBin         = 7
+	typeStringArray = 8
+	typeI18NString  = 9
+)
+
+// header tags used to build repository metadata
+const (
+	tagName            = 1000
+	tagVersion         = 1001
+	tagRelease         = 1002
+	tagEpoch           = 1003
+	tagSummary         = 1004
+	tagDescription     = 1005
+	tagBuildTime       = 1006
+	tagBuildHost       = 1007
+	tagSize            = 1009
+	tagVendor          = 1011
+	tagLicense         = 1014
+	tagPackager        = 1015
+	tagGroup           = 1016
+	tagURL             = 1020
+	tagArch            = 1022
+	tagFileModes       = 1030
+	tagFileFlags       = 1037
+	tagSourceRPM       = 1044
+	tagArchiveSize     = 1046
+	tagProvideName     = 1047
+	tagRequireFlags    = 1048
+	tagRequireName     = 1049
+	tagRequireVersion  = 1050
+	tagConflictFlags   = 1053
+	tagConflictName    = 1054
+	tagConflictVersion = 1055
+	tagChangelogTime   = 1080
+	tagChangelogName   = 1081
+	tagChangelogText   = 1082
+	tagObsoleteName    = 1090
+	tagProvideFlags    = 1112
+	tagProvideVersion  = 1113
+	tagObsoleteFlags   = 1114
+	tagObsoleteVersion = 1115
+	tagDirIndexes      = 1116
+	tagBaseNames       = 1117
+	tagDirNames        = 1118
+	tagLongSize        = 5009
+
+	// signature header tags
+	sigTagPayloadSize = 1007
+)
+
+// header is a parsed RPM header structure.
+type header struct {
+	entries map[int32]indexEntry
+	data    []byte
+}
+
+type indexEntry struct {
+	typ    int32
+	offset int32
+	count  int32
+}
+
+// readHeader reads a header structure from r, returning the header
+// and the number of bytes read.
+func readHeader(r io.Reader) (*header, int64, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return nil, 0, fmt.Errorf("error reading header: %w", err)
+	}
+	if !bytes.Equal(intro[0:4], headerMagic) {
+		return nil, 0, errors.New("invalid header magic")
+	}
+
+	nindex := binary.BigEndian.Uint32(intro[8:12])
+	hsize := binary.BigEndian.Uint32(intro[12:16])
+
+	// guard against allocating huge buffers for corrupt files
+	if nindex > 1<<16 || hsize > 1<<28 {
+		return nil, 0, fmt.Errorf("header too large: %d entries, %d bytes", nindex, hsize)
+	}
+
+	index := make([]byte, 16*nindex)
+	if _, err := io.ReadFull(r, index); err != nil {
+		return nil, 0, fmt.Errorf("error reading header index: %w", err)
+	}
+
+	h := header{
+		entries: map[int32]indexEntry{},
+		data:    make([]byte, hsize),
+	}
+
+	if _, err := io.ReadFull(r, h.data); err != nil {
+		return nil, 0, fmt.Errorf("error reading header data: %w", err)
+	}
+
+	for i := uint32(0); i < nindex; i++ {
+		e := index[i*16 : (i+1)*16]
+		tag := int32(binary.BigEndian.Uint32(e[0:4]))
+		h.entries[tag] = indexEntry{
+			typ:    int32(binary.BigEndian.Uint32(e[4:8])),
+			offset: int32(binary.BigEndian.Uint32(e[8:12])),
+			count:  int32(binary.BigEndian.Uint32(e[12:16])),
+		}
+	}
+
+	return &h, int64(16 + len(index) + len(h.data)), nil
+}
+
+// String returns the value of a string tag, or the first value of
+// a string array tag. An empty string is returned if the tag is missing.
+func (h *header) String(tag int32) string {
+	values := h.Strings(tag)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Strings returns the values of a string or string array tag.
+func (h *header) Strings(tag int32) []string {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+
+	switch e.typ {
+	case typeString, typeStringArray, typeI18NString:
+	default:
+		return nil
+	}
+
+	count := int(e.count)
+	if e.typ == typeString {
+		count = 1
+	}
+
+	var values []string
+	offset := int(e.offset)
+	for i := 0; i < count; i++ {
+		if offset < 0 || offset >= len(h.data) {
+			break
+		}
+		end := bytes.IndexByte(h.data[offset:], 0)
+		if end == -1 {
+			values = append(values, string(h.data[offset:]))
+			break
+		}
+		values = append(values, string(h.data[offset:offset+end]))
+		offset += end + 1
+
+		// i18n strings are stored per locale; the first is the default
+		if e.typ == typeI18NString {
+			break
+		}
+	}
+
+	return values
+}
+
+// Int returns the first value of an integer tag, or 0 if the tag is missing.
+func (h *header) Int(tag int32) int64 {
+	values := h.Ints(tag)
+	if len(values) == 0 {
+		return 0
+	}
+	return values[0]
+}
+
+// Has reports whether the header contains a tag.
+func (h *header) Has(tag int32) bool {
+	_, ok := h.entries[tag]
+	return ok
+}
+
+// Ints returns the values of an integer tag.
+func (h *header) Ints(tag int32) []int64 {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+
+	var size int
+	switch e.typ {
+	case typeChar, typeInt8:
+		size = 1
+	case typeInt16:
+		size = 2
+	case typeInt32:
+		size = 4
+	case typeInt64:
+		size = 8
+	default:
+		return nil
+	}
+
+	var values []int64
+	for i := 0; i < int(e.count); i++ {
+		start := int(e.offset) + i*size
+		if start < 0 || start+size > len(h.data) {
+			break
+		}
+		b := h.data[start : start+size]
+
+		switch size {
+		case 1:
+			values = append(values, int64(b[0]))
+		case 2:
+			values = append(values, int64(binary.BigEndian.Uint16(b)))
+		case 4:
+			values = append(values, int64(binary.BigEndian.Uint32(b)))
+		case 8:
+			values = append(values, int64(binary.BigEndian.Uint64(b)))
+		}
+	}
+
+	return values
+}
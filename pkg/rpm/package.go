@@ -0,0 +1,288 @@
+package rpm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Package is the metadata read from the header of an .rpm package.
+type Package struct {
+	Name          string
+	Epoch         int64
+	Version       string
+	Release       string
+	Arch          string
+	Summary       string
+	Description   string
+	Packager      string
+	URL           string
+	License       string
+	Vendor        string
+	Group         string
+	BuildHost     string
+	SourceRPM     string
+	BuildTime     int64
+	InstalledSize int64
+	ArchiveSize   int64
+	Provides      []Dependency
+	Requires      []Dependency
+	Conflicts     []Dependency
+	Obsoletes     []Dependency
+	Files         []File
+	Changelogs    []Changelog
+
+	// HeaderStart and HeaderEnd are the byte range of the main header
+	// within the package file.
+	HeaderStart int64
+	HeaderEnd   int64
+}
+
+// Dependency is a capability provided or required by a package.
+type Dependency struct {
+	Name    string
+	Flags   string
+	Epoch   string
+	Version string
+	Release string
+	// Pre is set for requirements needed by install scriptlets.
+	Pre bool
+}
+
+// File is a file installed by a package.
+type File struct {
+	Path string
+	// Type is "dir" or "ghost" for directories and ghost files,
+	// and empty for regular files.
+	Type string
+}
+
+// Changelog is a changelog entry from a package.
+type Changelog struct {
+	Author string
+	Date   int64
+	Text   string
+}
+
+// dependency sense flags
+const (
+	senseLess    = 0x02
+	senseGreater = 0x04
+	senseEqual   = 0x08
+	sensePrereq  = 0x40
+	senseInterp  = 0x100
+	senseScripts = 0x200 | 0x400 | 0x800 | 0x1000 // pre, post, preun, postun
+	senseRPMLib  = 0x1000000
+)
+
+const (
+	fileGhost = 0x40
+	modeDir   = 0o040000
+	modeMask  = 0o170000
+)
+
+// Read reads the headers of an .rpm package from r. Only the lead and
+// headers are read; the payload is left unread.
+func Read(r io.Reader) (*Package, error) {
+	br := bufio.NewReader(r)
+
+	lead := make([]byte, leadSize)
+	if _, err := io.ReadFull(br, lead); err != nil {
+		return nil, fmt.Errorf("error reading rpm lead: %w", err)
+	}
+	if !bytes.Equal(lead[0:4], leadMagic) {
+		return nil, errors.New("not an .rpm package: missing lead magic")
+	}
+
+	// the lead records whether this is a binary or source package
+	if binary.BigEndian.Uint16(lead[6:8]) != 0 {
+		return nil, errors.New("source rpms are not supported")
+	}
+
+	sig, sigSize, err := readHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signature header: %w", err)
+	}
+
+	// the signature header is padded to an 8 byte boundary
+	pad := (8 - sigSize%8) % 8
+	if _, err := br.Discard(int(pad)); err != nil {
+		return nil, fmt.Errorf("error reading signature header: %w", err)
+	}
+
+	h, size, err := readHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("error reading main header: %w", err)
+	}
+
+	start := leadSize + sigSize + pad
+
+	p := Package{
+		Name:          h.String(tagName),
+		Epoch:         h.Int(tagEpoch),
+		Version:       h.String(tagVersion),
+		Release:       h.String(tagRelease),
+		Arch:          h.String(tagArch),
+		Summary:       h.String(tagSummary),
+		Description:   h.String(tagDescription),
+		Packager:      h.String(tagPackager),
+		URL:           h.String(tagURL),
+		License:       h.String(tagLicense),
+		Vendor:        h.String(tagVendor),
+		Group:         h.String(tagGroup),
+		BuildHost:     h.String(tagBuildHost),
+		SourceRPM:     h.String(tagSourceRPM),
+		BuildTime:     h.Int(tagBuildTime),
+		InstalledSize: h.Int(tagSize),
+		ArchiveSize:   h.Int(tagArchiveSize),
+		HeaderStart:   start,
+		HeaderEnd:     start + size,
+	}
+
+	if h.Has(tagLongSize) {
+		p.InstalledSize = h.Int(tagLongSize)
+	}
+	if sig.Has(sigTagPayloadSize) {
+		p.ArchiveSize = sig.Int(sigTagPayloadSize)
+	}
+
+	if p.Name == "" || p.Version == "" {
+		return nil, errors.New("invalid .rpm package: missing name or version")
+	}
+
+	p.Provides = dependencies(h, tagProvideName, tagProvideFlags, tagProvideVersion)
+	p.Requires = dependencies(h, tagRequireName, tagRequireFlags, tagRequireVersion)
+	p.Conflicts = dependencies(h, tagConflictName, tagConflictFlags, tagConflictVersion)
+	p.Obsoletes = dependencies(h, tagObsoleteName, tagObsoleteFlags, tagObsoleteVersion)
+	p.Files = files(h)
+
+	names := h.Strings(tagChangelogName)
+	times := h.Ints(tagChangelogTime)
+	texts := h.Strings(tagChangelogText)
+	for i := range names {
+		if i >= len(times) || i >= len(texts) {
+			break
+		}
+		p.Changelogs = append(p.Changelogs, Changelog{Author: names[i], Date: times[i], Text: texts[i]})
+	}
+
+	return &p, nil
+}
+
+// NEVRA returns the name, epoch, version, release and architecture
+// which uniquely identify the package.
+func (p *Package) NEVRA() NEVRA {
+	return NEVRA{
+		Name:    p.Name,
+		Epoch:   strconv.FormatInt(p.Epoch, 10),
+		Version: p.Version,
+		Release: p.Release,
+		Arch:    p.Arch,
+	}
+}
+
+// Filename returns the conventional file name for the package.
+func (p *Package) Filename() string {
+	return fmt.Sprintf("%s-%s-%s.%s.rpm", p.Name, p.Version, p.Release, p.Arch)
+}
+
+func dependencies(h *header, nameTag, flagsTag, versionTag int32) []Dependency {
+	names := h.Strings(nameTag)
+	flags := h.Ints(flagsTag)
+	versions := h.Strings(versionTag)
+
+	var deps []Dependency
+	for i, name := range names {
+		var f int64
+		if i < len(flags) {
+			f = flags[i]
+		}
+
+		// rpmlib() dependencies are internal to rpm and
+		// are not included in repository metadata
+		if f&senseRPMLib != 0 || strings.HasPrefix(name, "rpmlib(") {
+			continue
+		}
+
+		d := Dependency{
+			Name:  name,
+			Flags: senseFlags(f),
+			Pre:   f&(sensePrereq|senseInterp|senseScripts) != 0,
+		}
+
+		if d.Flags != "" && i < len(versions) {
+			d.Epoch, d.Version, d.Release = splitEVR(versions[i])
+		}
+
+		deps = append(deps, d)
+	}
+
+	return deps
+}
+
+// senseFlags converts dependency sense flags to the comparison
+// used in repository metadata.
+func senseFlags(f int64) string {
+	switch f & (senseLess | senseGreater | senseEqual) {
+	case senseLess:
+		return "LT"
+	case senseGreater:
+		return "GT"
+	case senseEqual:
+		return "EQ"
+	case senseLess | senseEqual:
+		return "LE"
+	case senseGreater | senseEqual:
+		return "GE"
+	}
+	return ""
+}
+
+// splitEVR splits a version string of the form [epoch:]version[-release].
+func splitEVR(evr string) (epoch, version, release string) {
+	epoch = "0"
+	if e, rest, found := strings.Cut(evr, ":"); found {
+		epoch = e
+		evr = rest
+	}
+
+	version = evr
+	if i := strings.LastIndex(evr, "-"); i != -1 {
+		version = evr[:i]
+		release = evr[i+1:]
+	}
+
+	return epoch, version, release
+}
+
+func files(h *header) []File {
+	dirNames := h.Strings(tagDirNames)
+	baseNames := h.Strings(tagBaseNames)
+	dirIndexes := h.Ints(tagDirIndexes)
+	modes := h.Ints(tagFileModes)
+	flags := h.Ints(tagFileFlags)
+
+	var files []File
+	for i, base := range baseNames {
+		if i >= len(dirIndexes) || int(dirIndexes[i]) >= len(dirNames) {
+			break
+		}
+
+		f := File{Path: dirNames[dirIndexes[i]] + base}
+
+		if i < len(flags) && flags[i]&fileGhost != 0 {
+			f.Type = "ghost"
+		} else if i < len(modes) && modes[i]&modeMask == modeDir {
+			f.Type = "dir"
+		}
+
+		files = append(files, f)
+	}
+
+	return files
+}
@@ -0,0 +1,107 @@
+package rpm
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestRead(t *testing.T) {
+	f, err := os.Open("testdata/granted-0.27.5-1.x86_64.rpm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Package{
+		Name:        "granted",
+		Epoch:       0,
+		Version:     "0.27.5",
+		Release:     "1",
+		Arch:        "x86_64",
+		Summary:     "The easiest way to access your cloud.",
+		Description: "Granted is a CLI.",
+		URL:         "https://granted.dev",
+		License:     "MIT",
+		Vendor:      "Common Fate",
+		Group:       "Utilities",
+		BuildTime:   1700000000,
+		Provides: []Dependency{
+			{Name: "granted", Flags: "EQ", Epoch: "0", Version: "0.27.5", Release: "1"},
+		},
+		Requires: []Dependency{
+			{Name: "glibc", Flags: "GE", Epoch: "0", Version: "2.17"},
+		},
+		Files: []File{
+			{Path: "/usr/bin/granted"},
+			{Path: "/usr/share/doc/granted/README"},
+		},
+	}
+
+	opts := cmpopts.IgnoreFields(Package{}, "InstalledSize", "ArchiveSize", "SourceRPM", "BuildHost", "HeaderStart", "HeaderEnd")
+	if diff := cmp.Diff(want, got, opts); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+
+	if got.HeaderStart <= leadSize || got.HeaderEnd <= got.HeaderStart {
+		t.Errorf("invalid header range %d-%d", got.HeaderStart, got.HeaderEnd)
+	}
+}
+
+func TestRepository_RoundTrip(t *testing.T) {
+	f, err := os.Open("testdata/granted-0.27.5-1.x86_64.rpm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	pkg, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var repo Repository
+	err = repo.Add(pkg, "abc123", 1770, 1700000000, "packages/x86_64/granted-0.27.5-1.x86_64.rpm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var primary, filelists, other bytes.Buffer
+	if err := repo.WritePrimary(&primary); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.WriteFilelists(&filelists); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.WriteOther(&other); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(primary.String(), "<rpm:license>MIT</rpm:license>") {
+		t.Errorf("primary.xml is missing the licence:\n%s", primary.String())
+	}
+
+	var got Repository
+	if err := got.ReadPrimary(primary.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.ReadFilelists(filelists.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.ReadOther(other.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(repo, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
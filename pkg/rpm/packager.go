@@ -0,0 +1,301 @@
+package rpm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/checksum"
+	"github.com/common-fate/linuxpack/pkg/compression"
+	"github.com/common-fate/linuxpack/pkg/output"
+	"github.com/common-fate/linuxpack/pkg/plan"
+	"github.com/common-fate/linuxpack/pkg/signing"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// Packager publishes .rpm packages to a YUM/DNF repository.
+//
+// Each channel is a separate repository, stored under rpm/<channel>/.
+type Packager struct {
+	// Storage holds the published repository, which
+	// the new packages are merged with.
+	Storage      storage.Storage
+	OutputFolder string
+	Channel      string
+	Files        []string
+	// Signer signs repomd.xml, if set, producing repomd.xml.asc.
+	Signer signing.Signer
+	// DryRun skips copying packages into the output folder and signing
+	// repomd.xml, so that changes can be planned without publishing them.
+	DryRun bool
+	// Plan records the changes made to the repository, if set.
+	Plan *plan.Plan
+}
+
+// metadataTypes are the metadata files maintained by the packager.
+var metadataTypes = []string{"primary", "filelists", "other"}
+
+func (p Packager) Package(ctx context.Context) error {
+	repoPath := path.Join("rpm", p.Channel)
+
+	repo, existing, err := p.readExistingRepository(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+
+	outPath := filepath.Join(p.OutputFolder, "rpm", p.Channel)
+
+	for _, fileName := range p.Files {
+		err = p.addFile(&repo, repoPath, outPath, fileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	repodataPath := filepath.Join(outPath, "repodata")
+	err = os.MkdirAll(repodataPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	md := Repomd{Revision: strconv.FormatInt(now.Unix(), 10)}
+
+	writers := map[string]func(w io.Writer) error{
+		"primary":   repo.WritePrimary,
+		"filelists": repo.WriteFilelists,
+		"other":     repo.WriteOther,
+	}
+
+	for _, typ := range metadataTypes {
+		var buf bytes.Buffer
+		err = writers[typ](&buf)
+		if err != nil {
+			return err
+		}
+
+		data, err := writeMetadata(repodataPath, typ, buf.Bytes(), now)
+		if err != nil {
+			return err
+		}
+		md.Data = append(md.Data, data)
+	}
+
+	// metadata files are named by their checksum, so the
+	// previous files are left unreferenced when they change
+	for _, old := range existing.Data {
+		if _, ok := md.Find(old.Type); ok && !slices.ContainsFunc(md.Data, func(d RepomdData) bool { return d.Location.Href == old.Location.Href }) {
+			p.Plan.AddDelete(path.Join(repoPath, old.Location.Href))
+		}
+	}
+
+	repomdPath := filepath.Join(repodataPath, "repomd.xml")
+	repomdFile, err := os.Create(repomdPath)
+	if err != nil {
+		return err
+	}
+	defer repomdFile.Close()
+
+	err = md.Write(repomdFile)
+	if err != nil {
+		return err
+	}
+
+	err = repomdFile.Close()
+	if err != nil {
+		return fmt.Errorf("error closing repomd.xml: %w", err)
+	}
+
+	if p.Signer != nil && !p.DryRun {
+		err = p.Signer.DetachSign(ctx, repomdPath, repomdPath+".asc", true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFile reads an .rpm package, copies it into the output folder
+// and adds it to the repository.
+func (p Packager) addFile(repo *Repository, repoPath string, outPath string, fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// compute the package checksum and copy the file in the same
+	// pass over the file as reading the package headers. The path
+	// depends on the package architecture, so the file is copied to
+	// a temporary file which is renamed once the headers are read.
+	hasher := checksum.NewHasher(checksum.SHA256)
+	w := io.Writer(hasher)
+
+	var tmp *output.TempFile
+	if !p.DryRun {
+		tmp, err = output.CreateTemp(outPath, fileInfo.Name())
+		if err != nil {
+			return err
+		}
+		defer tmp.Cleanup()
+
+		w = io.MultiWriter(hasher, tmp)
+	}
+
+	pkg, err := Read(io.TeeReader(file, w))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	pkgID := hasher.Sums()[checksum.SHA256]
+
+	location := path.Join("packages", pkg.Arch, fileInfo.Name())
+	fmt.Printf("adding %s as %s\n", pkg.NEVRA(), location)
+
+	change := plan.PackageChange{
+		Action:  plan.Added,
+		Format:  "rpm",
+		Index:   path.Join(repoPath, "repodata", "repomd.xml"),
+		Name:    pkg.Name,
+		Version: pkg.NEVRA().EVR(),
+		Arch:    pkg.Arch,
+	}
+	if _, ok := repo.Packages[pkg.NEVRA()]; ok {
+		change.Action = plan.Replaced
+	}
+	p.Plan.AddPackage(change)
+	p.Plan.AddUpload(path.Join(repoPath, location), fileInfo.Size())
+
+	if !p.DryRun {
+		err = tmp.Commit(filepath.Join(outPath, filepath.FromSlash(location)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return repo.Add(pkg, pkgID, fileInfo.Size(), fileInfo.ModTime().Unix(), location)
+}
+
+// readExistingRepository reads the existing repository metadata from storage,
+// returning the repository and the repomd.xml it was read from.
+func (p Packager) readExistingRepository(ctx context.Context, repoPath string) (Repository, Repomd, error) {
+	key := path.Join(repoPath, "repodata", "repomd.xml")
+	fmt.Printf("reading existing repository metadata from %s\n", p.Storage.URL(key))
+
+	body, err := p.Storage.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		fmt.Printf("no repository metadata found\n")
+		return Repository{}, Repomd{}, nil
+	}
+	if err != nil {
+		return Repository{}, Repomd{}, err
+	}
+	md, err := ReadRepomd(body)
+	body.Close()
+	if err != nil {
+		return Repository{}, Repomd{}, fmt.Errorf("error reading %s: %w", key, err)
+	}
+
+	var repo Repository
+
+	readers := map[string]func(data []byte) error{
+		"primary":   repo.ReadPrimary,
+		"filelists": repo.ReadFilelists,
+		"other":     repo.ReadOther,
+	}
+
+	// primary must be read first, as the other metadata
+	// files are attached to the packages it lists
+	for _, typ := range metadataTypes {
+		d, ok := md.Find(typ)
+		if !ok {
+			continue
+		}
+
+		data, err := p.readMetadata(ctx, path.Join(repoPath, d.Location.Href))
+		if err != nil {
+			return Repository{}, Repomd{}, err
+		}
+
+		err = readers[typ](data)
+		if err != nil {
+			return Repository{}, Repomd{}, fmt.Errorf("error reading %s metadata: %w", typ, err)
+		}
+	}
+
+	return repo, md, nil
+}
+
+// readMetadata reads and decompresses a metadata file from storage.
+func (p Packager) readMetadata(ctx context.Context, key string) ([]byte, error) {
+	fmt.Printf("reading %s\n", p.Storage.URL(key))
+
+	body, err := p.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", key, err)
+	}
+	defer body.Close()
+
+	r, err := compression.FromExt(key).NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing %s: %w", key, err)
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// writeMetadata writes a gzipped metadata file to the repodata folder.
+// As with createrepo, the file name is prefixed with its checksum so that
+// clients and caches never see a mismatched repomd.xml and metadata file.
+func writeMetadata(repodataPath string, typ string, data []byte, now time.Time) (RepomdData, error) {
+	var buf bytes.Buffer
+	w, err := compression.Gzip.NewWriter(&buf)
+	if err != nil {
+		return RepomdData{}, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return RepomdData{}, err
+	}
+	if err := w.Close(); err != nil {
+		return RepomdData{}, err
+	}
+
+	openHasher := checksum.NewHasher(checksum.SHA256)
+	openHasher.Write(data)
+
+	hasher := checksum.NewHasher(checksum.SHA256)
+	hasher.Write(buf.Bytes())
+	sum := hasher.Sums()[checksum.SHA256]
+
+	name := fmt.Sprintf("%s-%s.xml.gz", sum, typ)
+	err = os.WriteFile(filepath.Join(repodataPath, name), buf.Bytes(), 0644)
+	if err != nil {
+		return RepomdData{}, err
+	}
+
+	return RepomdData{
+		Type:         typ,
+		Checksum:     RepomdChecksum{Type: "sha256", Value: sum},
+		OpenChecksum: &RepomdChecksum{Type: "sha256", Value: openHasher.Sums()[checksum.SHA256]},
+		Location:     RepomdLocation{Href: path.Join("repodata", name)},
+		Timestamp:    now.Unix(),
+		Size:         int64(buf.Len()),
+		OpenSize:     int64(len(data)),
+	}, nil
+}
@@ -0,0 +1,461 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	nsCommon    = "http://linux.duke.edu/metadata/common"
+	nsRPM       = "http://linux.duke.edu/metadata/rpm"
+	nsFilelists = "http://linux.duke.edu/metadata/filelists"
+	nsOther     = "http://linux.duke.edu/metadata/other"
+	nsRepo      = "http://linux.duke.edu/metadata/repo"
+)
+
+// NEVRA uniquely identifies a package in a repository.
+type NEVRA struct {
+	Name    string
+	Epoch   string
+	Version string
+	Release string
+	Arch    string
+}
+
+func (n NEVRA) String() string {
+	return fmt.Sprintf("%s-%s:%s-%s.%s", n.Name, n.Epoch, n.Version, n.Release, n.Arch)
+}
+
+// EVR returns the epoch, version and release of the package,
+// such as 1:0.27.5-1. A zero epoch is omitted.
+func (n NEVRA) EVR() string {
+	if n.Epoch == "" || n.Epoch == "0" {
+		return n.Version + "-" + n.Release
+	}
+	return n.Epoch + ":" + n.Version + "-" + n.Release
+}
+
+// Repository holds the packages described by a repository's
+// primary, filelists and other metadata.
+//
+// Packages read from existing metadata are kept as the raw XML elements
+// they were read from, so that metadata which linuxpack doesn't understand
+// is preserved when the repository is rewritten.
+type Repository struct {
+	Packages map[NEVRA]*Entry
+}
+
+// Entry is a package's XML elements in each of the metadata files.
+type Entry struct {
+	PkgID     string
+	Primary   []byte
+	Filelists []byte
+	Other     []byte
+}
+
+// Add adds a package to the repository, replacing any existing
+// package with the same NEVRA. pkgID is the SHA256 checksum of the
+// package file and location is its path relative to the repository root.
+func (r *Repository) Add(p *Package, pkgID string, size int64, modTime int64, location string) error {
+	if r.Packages == nil {
+		r.Packages = map[NEVRA]*Entry{}
+	}
+
+	e := Entry{PkgID: pkgID}
+	var err error
+
+	e.Primary, err = xml.Marshal(newPrimaryPackage(p, pkgID, size, modTime, location))
+	if err != nil {
+		return err
+	}
+
+	e.Filelists, err = xml.Marshal(newFilelistsPackage(p, pkgID))
+	if err != nil {
+		return err
+	}
+
+	e.Other, err = xml.Marshal(newOtherPackage(p, pkgID))
+	if err != nil {
+		return err
+	}
+
+	r.Packages[p.NEVRA()] = &e
+	return nil
+}
+
+// sortedKeys returns the repository's packages in a stable order.
+func (r *Repository) sortedKeys() []NEVRA {
+	var keys []NEVRA
+	for k := range r.Packages {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b NEVRA) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return keys
+}
+
+// WritePrimary writes primary.xml.
+func (r *Repository) WritePrimary(w io.Writer) error {
+	return r.write(w, "metadata", fmt.Sprintf(`xmlns="%s" xmlns:rpm="%s"`, nsCommon, nsRPM), func(e *Entry) []byte {
+		return e.Primary
+	})
+}
+
+// WriteFilelists writes filelists.xml.
+func (r *Repository) WriteFilelists(w io.Writer) error {
+	return r.write(w, "filelists", fmt.Sprintf(`xmlns="%s"`, nsFilelists), func(e *Entry) []byte {
+		return e.Filelists
+	})
+}
+
+// WriteOther writes other.xml.
+func (r *Repository) WriteOther(w io.Writer) error {
+	return r.write(w, "otherdata", fmt.Sprintf(`xmlns="%s"`, nsOther), func(e *Entry) []byte {
+		return e.Other
+	})
+}
+
+func (r *Repository) write(w io.Writer, root string, attrs string, element func(e *Entry) []byte) error {
+	keys := r.sortedKeys()
+
+	_, err := fmt.Fprintf(w, "%s<%s %s packages=\"%d\">\n", xml.Header, root, attrs, len(keys))
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		el := element(r.Packages[k])
+		if el == nil {
+			// the existing metadata didn't contain an entry for this
+			// package, so write a minimal one
+			el, err = xml.Marshal(idPackage{
+				PkgID:   r.Packages[k].PkgID,
+				Name:    k.Name,
+				Arch:    k.Arch,
+				Version: versionXML{Epoch: k.Epoch, Ver: k.Version, Rel: k.Release},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = fmt.Fprintf(w, "%s\n", el)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "</%s>\n", root)
+	return err
+}
+
+// ReadPrimary reads packages from primary.xml into the repository.
+func (r *Repository) ReadPrimary(data []byte) error {
+	return eachPackage(data, func(raw []byte) error {
+		var p struct {
+			Name     string     `xml:"name"`
+			Arch     string     `xml:"arch"`
+			Version  versionXML `xml:"version"`
+			Checksum struct {
+				Value string `xml:",chardata"`
+			} `xml:"checksum"`
+		}
+		if err := xml.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+
+		if r.Packages == nil {
+			r.Packages = map[NEVRA]*Entry{}
+		}
+
+		key := NEVRA{Name: p.Name, Epoch: p.Version.epoch(), Version: p.Version.Ver, Release: p.Version.Rel, Arch: p.Arch}
+		r.Packages[key] = &Entry{PkgID: strings.TrimSpace(p.Checksum.Value), Primary: raw}
+		return nil
+	})
+}
+
+// ReadFilelists reads filelists.xml, attaching the file lists to packages
+// previously read with ReadPrimary.
+func (r *Repository) ReadFilelists(data []byte) error {
+	byID := r.byPkgID()
+	return eachPackage(data, func(raw []byte) error {
+		var p idPackage
+		if err := xml.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		if e, ok := byID[p.PkgID]; ok {
+			e.Filelists = raw
+		}
+		return nil
+	})
+}
+
+// ReadOther reads other.xml, attaching the changelogs to packages
+// previously read with ReadPrimary.
+func (r *Repository) ReadOther(data []byte) error {
+	byID := r.byPkgID()
+	return eachPackage(data, func(raw []byte) error {
+		var p idPackage
+		if err := xml.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		if e, ok := byID[p.PkgID]; ok {
+			e.Other = raw
+		}
+		return nil
+	})
+}
+
+func (r *Repository) byPkgID() map[string]*Entry {
+	byID := map[string]*Entry{}
+	for _, e := range r.Packages {
+		byID[e.PkgID] = e
+	}
+	return byID
+}
+
+// eachPackage calls fn with the raw XML of each top-level <package> element.
+func eachPackage(data []byte, fn func(raw []byte) error) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	depth := 0
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 1 && t.Name.Local == "package" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				raw := bytes.Clone(data[start:d.InputOffset()])
+				if err := fn(raw); err != nil {
+					return err
+				}
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+type versionXML struct {
+	Epoch string `xml:"epoch,attr"`
+	Ver   string `xml:"ver,attr"`
+	Rel   string `xml:"rel,attr"`
+}
+
+func (v versionXML) epoch() string {
+	if v.Epoch == "" {
+		return "0"
+	}
+	return v.Epoch
+}
+
+// idPackage is the identifying part of a package element
+// in filelists.xml and other.xml.
+type idPackage struct {
+	XMLName xml.Name   `xml:"package"`
+	PkgID   string     `xml:"pkgid,attr"`
+	Name    string     `xml:"name,attr"`
+	Arch    string     `xml:"arch,attr"`
+	Version versionXML `xml:"version"`
+}
+
+type primaryPackage struct {
+	XMLName     xml.Name      `xml:"package"`
+	Type        string        `xml:"type,attr"`
+	Name        string        `xml:"name"`
+	Arch        string        `xml:"arch"`
+	Version     versionXML    `xml:"version"`
+	Checksum    checksumXML   `xml:"checksum"`
+	Summary     string        `xml:"summary"`
+	Description string        `xml:"description"`
+	Packager    string        `xml:"packager"`
+	URL         string        `xml:"url"`
+	Time        timeXML       `xml:"time"`
+	Size        sizeXML       `xml:"size"`
+	Location    locationXML   `xml:"location"`
+	Format      primaryFormat `xml:"format"`
+}
+
+type checksumXML struct {
+	Type  string `xml:"type,attr"`
+	PkgID string `xml:"pkgid,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type timeXML struct {
+	File  int64 `xml:"file,attr"`
+	Build int64 `xml:"build,attr"`
+}
+
+type sizeXML struct {
+	Package   int64 `xml:"package,attr"`
+	Installed int64 `xml:"installed,attr"`
+	Archive   int64 `xml:"archive,attr"`
+}
+
+type locationXML struct {
+	Href string `xml:"href,attr"`
+}
+
+type primaryFormat struct {
+	License     string          `xml:"rpm:license"`
+	Vendor      string          `xml:"rpm:vendor"`
+	Group       string          `xml:"rpm:group"`
+	BuildHost   string          `xml:"rpm:buildhost"`
+	SourceRPM   string          `xml:"rpm:sourcerpm"`
+	HeaderRange headerRangeXML  `xml:"rpm:header-range"`
+	Provides    *dependencyList `xml:"rpm:provides,omitempty"`
+	Requires    *dependencyList `xml:"rpm:requires,omitempty"`
+	Conflicts   *dependencyList `xml:"rpm:conflicts,omitempty"`
+	Obsoletes   *dependencyList `xml:"rpm:obsoletes,omitempty"`
+	Files       []fileXML       `xml:"file"`
+}
+
+type headerRangeXML struct {
+	Start int64 `xml:"start,attr"`
+	End   int64 `xml:"end,attr"`
+}
+
+type dependencyList struct {
+	Entries []dependencyXML `xml:"rpm:entry"`
+}
+
+type dependencyXML struct {
+	Name  string `xml:"name,attr"`
+	Flags string `xml:"flags,attr,omitempty"`
+	Epoch string `xml:"epoch,attr,omitempty"`
+	Ver   string `xml:"ver,attr,omitempty"`
+	Rel   string `xml:"rel,attr,omitempty"`
+	Pre   string `xml:"pre,attr,omitempty"`
+}
+
+type fileXML struct {
+	Type string `xml:"type,attr,omitempty"`
+	Path string `xml:",chardata"`
+}
+
+type filelistsPackage struct {
+	idPackage
+	Files []fileXML `xml:"file"`
+}
+
+type otherPackage struct {
+	idPackage
+	Changelogs []changelogXML `xml:"changelog"`
+}
+
+type changelogXML struct {
+	Author string `xml:"author,attr"`
+	Date   int64  `xml:"date,attr"`
+	Text   string `xml:",chardata"`
+}
+
+func newPrimaryPackage(p *Package, pkgID string, size int64, modTime int64, location string) primaryPackage {
+	n := p.NEVRA()
+
+	pp := primaryPackage{
+		Type:        "rpm",
+		Name:        p.Name,
+		Arch:        p.Arch,
+		Version:     versionXML{Epoch: n.Epoch, Ver: p.Version, Rel: p.Release},
+		Checksum:    checksumXML{Type: "sha256", PkgID: "YES", Value: pkgID},
+		Summary:     p.Summary,
+		Description: p.Description,
+		Packager:    p.Packager,
+		URL:         p.URL,
+		Time:        timeXML{File: modTime, Build: p.BuildTime},
+		Size:        sizeXML{Package: size, Installed: p.InstalledSize, Archive: p.ArchiveSize},
+		Location:    locationXML{Href: location},
+		Format: primaryFormat{
+			License:     p.License,
+			Vendor:      p.Vendor,
+			Group:       p.Group,
+			BuildHost:   p.BuildHost,
+			SourceRPM:   p.SourceRPM,
+			HeaderRange: headerRangeXML{Start: p.HeaderStart, End: p.HeaderEnd},
+			Provides:    newDependencyList(p.Provides),
+			Requires:    newDependencyList(p.Requires),
+			Conflicts:   newDependencyList(p.Conflicts),
+			Obsoletes:   newDependencyList(p.Obsoletes),
+		},
+	}
+
+	// as with createrepo, primary.xml only lists the files which
+	// are commonly depended on by path
+	for _, f := range p.Files {
+		if isPrimaryFile(f.Path) {
+			pp.Format.Files = append(pp.Format.Files, fileXML{Type: f.Type, Path: f.Path})
+		}
+	}
+
+	return pp
+}
+
+func isPrimaryFile(path string) bool {
+	return strings.HasPrefix(path, "/etc/") || strings.Contains(path, "bin/") || path == "/usr/lib/sendmail"
+}
+
+func newDependencyList(deps []Dependency) *dependencyList {
+	if len(deps) == 0 {
+		return nil
+	}
+
+	var l dependencyList
+	for _, d := range deps {
+		e := dependencyXML{
+			Name:  d.Name,
+			Flags: d.Flags,
+			Epoch: d.Epoch,
+			Ver:   d.Version,
+			Rel:   d.Release,
+		}
+		if d.Pre {
+			e.Pre = "1"
+		}
+		l.Entries = append(l.Entries, e)
+	}
+	return &l
+}
+
+func newIDPackage(p *Package, pkgID string) idPackage {
+	n := p.NEVRA()
+	return idPackage{
+		PkgID:   pkgID,
+		Name:    p.Name,
+		Arch:    p.Arch,
+		Version: versionXML{Epoch: n.Epoch, Ver: p.Version, Rel: p.Release},
+	}
+}
+
+func newFilelistsPackage(p *Package, pkgID string) filelistsPackage {
+	fp := filelistsPackage{idPackage: newIDPackage(p, pkgID)}
+	for _, f := range p.Files {
+		fp.Files = append(fp.Files, fileXML{Type: f.Type, Path: f.Path})
+	}
+	return fp
+}
+
+func newOtherPackage(p *Package, pkgID string) otherPackage {
+	op := otherPackage{idPackage: newIDPackage(p, pkgID)}
+	for _, c := range p.Changelogs {
+		op.Changelogs = append(op.Changelogs, changelogXML{Author: c.Author, Date: c.Date, Text: c.Text})
+	}
+	return op
+}
@@ -0,0 +1,73 @@
+package rpm
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Repomd is the repomd.xml index of a repository's metadata files.
+type Repomd struct {
+	XMLName  xml.Name     `xml:"repomd"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	XmlnsRPM string       `xml:"xmlns:rpm,attr"`
+	Revision string       `xml:"revision"`
+	Data     []RepomdData `xml:"data"`
+}
+
+// RepomdData describes a single metadata file.
+type RepomdData struct {
+	Type         string          `xml:"type,attr"`
+	Checksum     RepomdChecksum  `xml:"checksum"`
+	OpenChecksum *RepomdChecksum `xml:"open-checksum,omitempty"`
+	Location     RepomdLocation  `xml:"location"`
+	Timestamp    int64           `xml:"timestamp"`
+	Size         int64           `xml:"size"`
+	OpenSize     int64           `xml:"open-size,omitempty"`
+}
+
+type RepomdChecksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type RepomdLocation struct {
+	Href string `xml:"href,attr"`
+}
+
+// Find returns the data entry with the given type, such as "primary".
+func (r *Repomd) Find(typ string) (RepomdData, bool) {
+	for _, d := range r.Data {
+		if d.Type == typ {
+			return d, true
+		}
+	}
+	return RepomdData{}, false
+}
+
+// ReadRepomd reads a repomd.xml file.
+func ReadRepomd(r io.Reader) (Repomd, error) {
+	var md Repomd
+	err := xml.NewDecoder(r).Decode(&md)
+	return md, err
+}
+
+// Write writes the repomd.xml file.
+func (r *Repomd) Write(w io.Writer) error {
+	r.Xmlns = nsRepo
+	r.XmlnsRPM = nsRPM
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package rpm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/common-fate/linuxpack/internal/testutil"
+	"github.com/common-fate/linuxpack/pkg/storage"
+)
+
+// TestPackager_Package_ManyFiles packages many more files than can be
+// open at once, which fails if file handles are held until Package returns.
+func TestPackager_Package_ManyFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	data, err := os.ReadFile("testdata/granted-0.27.5-1.x86_64.rpm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for i := 0; i < 300; i++ {
+		fileName := filepath.Join(dir, fmt.Sprintf("granted-%d.x86_64.rpm", i))
+		err = os.WriteFile(fileName, data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, fileName)
+	}
+
+	testutil.SetNoFileLimit(t, 64)
+
+	p := Packager{
+		Storage:      storage.Local{Dir: filepath.Join(dir, "published")},
+		OutputFolder: filepath.Join(dir, "dist"),
+		Channel:      "stable",
+		Files:        files,
+	}
+
+	err = p.Package(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package server serves a generated repository over HTTP, so that it can be
+// tested with package managers before it is published.
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/common-fate/linuxpack/pkg/packager"
+)
+
+// contentTypes maps file extensions to their content types. Files
+// which aren't listed are served as plain text if they are APT
+// indexes, or as binary data otherwise.
+var contentTypes = map[string]string{
+	".deb":  "application/vnd.debian.binary-package",
+	".rpm":  "application/x-rpm",
+	".apk":  "application/octet-stream",
+	".gz":   "application/gzip",
+	".xz":   "application/x-xz",
+	".zst":  "application/zstd",
+	".bz2":  "application/x-bzip2",
+	".gpg":  "application/pgp-signature",
+	".sig":  "application/pgp-signature",
+	".asc":  "application/pgp-signature",
+	".xml":  "application/xml",
+	".json": "application/json",
+}
+
+// textFiles are the APT index files which have no extension.
+var textFiles = map[string]bool{
+	"Release":        true,
+	"InRelease":      true,
+	"Packages":       true,
+	"Translation-en": true,
+}
+
+// Handler serves the repository in Dir. Range requests are supported.
+//
+// If PublicKey is set, it is served at /key.gpg. A sources.list snippet
+// for the APT suites in the repository is served at /sources.list.
+type Handler struct {
+	Dir       string
+	PublicKey []byte
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/key.gpg":
+		if h.PublicKey == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pgp-keys")
+		http.ServeContent(w, r, "key.gpg", time.Time{}, bytes.NewReader(h.PublicKey))
+		return
+	case "/sources.list":
+		h.serveSources(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType(r.URL.Path))
+	http.FileServer(http.Dir(h.Dir)).ServeHTTP(w, r)
+}
+
+// contentType returns the content type of a file in the repository.
+func contentType(name string) string {
+	base := path.Base(name)
+	if textFiles[base] {
+		return "text/plain; charset=utf-8"
+	}
+	if t, ok := contentTypes[path.Ext(base)]; ok {
+		return t
+	}
+	if strings.HasSuffix(name, "/") {
+		return "text/html; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// serveSources writes a sources.list snippet for each APT suite, using
+// the host of the request as the repository URL.
+func (h Handler) serveSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := h.Sources("http://" + r.Host + "/")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, sources)
+}
+
+// Sources returns a sources.list snippet for each APT suite in the
+// repository. If the repository has a public key, the entries are
+// signed by it. Otherwise they are marked as trusted, as the
+// repository is only served for testing.
+func (h Handler) Sources(url string) (string, error) {
+	releases, err := filepath.Glob(filepath.Join(h.Dir, "dists", "*", "Release"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(releases)
+
+	option := "[trusted=yes]"
+	var b strings.Builder
+
+	if h.PublicKey != nil {
+		// apt only reads armored keys from files with the .asc extension
+		keyring := "/usr/share/keyrings/linuxpack.gpg"
+		if bytes.HasPrefix(bytes.TrimSpace(h.PublicKey), []byte("-----BEGIN PGP")) {
+			keyring = "/usr/share/keyrings/linuxpack.asc"
+		}
+		option = "[signed-by=" + keyring + "]"
+		fmt.Fprintf(&b, "# install the key with: curl -fsSL %skey.gpg -o %s\n", url, keyring)
+	}
+
+	for _, releasePath := range releases {
+		f, err := os.Open(releasePath)
+		if err != nil {
+			return "", err
+		}
+		release, err := packager.ReadRelease(f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("error reading %s: %w", releasePath, err)
+		}
+
+		suite := filepath.Base(filepath.Dir(releasePath))
+		components := release.Components
+		if components == "" {
+			components = "main"
+		}
+
+		fmt.Fprintf(&b, "deb %s %s %s %s\n", option, url, suite, components)
+	}
+
+	return b.String(), nil
+}
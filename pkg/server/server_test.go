@@ -0,0 +1,115 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"dists/stable/Release":                       "Suite: stable\nComponents: main extra\n",
+		"dists/stable/main/binary-amd64/Packages":    "Package: granted\n",
+		"pool/amd64/stable/granted_0.27.5_amd64.deb": "0123456789",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := httptest.NewServer(Handler{Dir: dir, PublicKey: []byte("key")})
+	defer srv.Close()
+
+	tests := []struct {
+		name            string
+		path            string
+		rangeHeader     string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "index",
+			path:            "/dists/stable/main/binary-amd64/Packages",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "Package: granted\n",
+		},
+		{
+			name:            "package range",
+			path:            "/pool/amd64/stable/granted_0.27.5_amd64.deb",
+			rangeHeader:     "bytes=2-5",
+			wantStatus:      http.StatusPartialContent,
+			wantContentType: "application/vnd.debian.binary-package",
+			wantBody:        "2345",
+		},
+		{
+			name:            "key",
+			path:            "/key.gpg",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/pgp-keys",
+			wantBody:        "key",
+		},
+		{
+			name:            "sources",
+			path:            "/sources.list",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "# install the key with: curl -fsSL " + srv.URL + "/key.gpg -o /usr/share/keyrings/linuxpack.gpg\ndeb [signed-by=/usr/share/keyrings/linuxpack.gpg] " + srv.URL + "/ stable main extra\n",
+		},
+		{
+			name:       "not found",
+			path:       "/dists/nightly/Release",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus == http.StatusNotFound {
+				return
+			}
+
+			if got := res.Header.Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("expected content type %q, got %q", tt.wantContentType, got)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantBody, string(body)); diff != "" {
+				t.Errorf("body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package signing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Signer signs repository metadata files.
+type Signer interface {
+	// DetachSign writes a detached signature for the file at in to out.
+	// If armor is true, the signature is ASCII armored.
+	DetachSign(ctx context.Context, in, out string, armor bool) error
+	// ClearSign writes a clearsigned copy of the file at in to out.
+	ClearSign(ctx context.Context, in, out string) error
+}
+
+// GPG signs files using the gpg command line tool,
+// with a key from the user's keyring.
+type GPG struct {
+	// KeyID is the ID or fingerprint of the signing key.
+	KeyID string
+	// Homedir overrides the GnuPG home directory, if set.
+	Homedir string
+}
+
+func (g GPG) DetachSign(ctx context.Context, in, out string, armor bool) error {
+	args := []string{"--detach-sign"}
+	if armor {
+		args = append(args, "--armor")
+	}
+	return g.run(ctx, append(args, "--output", out, in)...)
+}
+
+func (g GPG) ClearSign(ctx context.Context, in, out string) error {
+	return g.run(ctx, "--clearsign", "--output", out, in)
+}
+
+// ExportPublicKey returns the binary public key, in the
+// format apt reads from keyring files with a .gpg extension.
+func (g GPG) ExportPublicKey(ctx context.Context) ([]byte, error) {
+	args := []string{"--batch", "--export"}
+	if g.Homedir != "" {
+		args = append(args, "--homedir", g.Homedir)
+	}
+
+	cmd := exec.CommandContext(ctx, "gpg", append(args, g.KeyID)...)
+	cmd.Stderr = os.Stderr
+
+	key, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running gpg: %w", err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("gpg key %q was not found", g.KeyID)
+	}
+	return key, nil
+}
+
+func (g GPG) run(ctx context.Context, args ...string) error {
+	base := []string{"--batch", "--yes", "--local-user", g.KeyID}
+	if g.Homedir != "" {
+		base = append(base, "--homedir", g.Homedir)
+	}
+
+	cmd := exec.CommandContext(ctx, "gpg", append(base, args...)...)
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("error running gpg: %w", err)
+	}
+	return nil
+}
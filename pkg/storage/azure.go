@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// azureVersion is the version of the Blob service REST API used.
+const azureVersion = "2021-08-06"
+
+// Azure stores a repository in an Azure Blob Storage container,
+// using the Blob service REST API.
+type Azure struct {
+	Client    *http.Client
+	Account   string
+	Container string
+	// Endpoint is the URL of the Blob service, such as
+	// https://<account>.blob.core.windows.net, or the URL of
+	// an emulator such as Azurite, which includes the account:
+	// http://127.0.0.1:10000/devstoreaccount1.
+	Endpoint string
+	// Key is the base64 encoded account key, used to sign requests
+	// with Shared Key authorization.
+	Key string
+	// SASToken is a shared access signature, used instead of the
+	// account key. It is the query string, such as sv=...&sig=....
+	SASToken string
+}
+
+// AzureOptions configures the client for an Azure Blob Storage container.
+type AzureOptions struct {
+	Account   string
+	Container string
+	// Endpoint defaults to https://<account>.blob.core.windows.net.
+	Endpoint string
+	// Key is the account key. If both Key and SASToken are empty,
+	// requests are anonymous, which is allowed for public containers.
+	Key      string
+	SASToken string
+}
+
+// NewAzure returns the storage for an Azure Blob Storage container.
+func NewAzure(opts AzureOptions) (Azure, error) {
+	if opts.Account == "" || opts.Container == "" {
+		return Azure{}, fmt.Errorf("an Azure storage account and container are required")
+	}
+
+	a := Azure{
+		Client:    http.DefaultClient,
+		Account:   opts.Account,
+		Container: opts.Container,
+		Endpoint:  opts.Endpoint,
+		Key:       opts.Key,
+		SASToken:  strings.TrimPrefix(opts.SASToken, "?"),
+	}
+
+	if a.Endpoint == "" {
+		a.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", opts.Account)
+	}
+
+	if a.Key != "" {
+		if _, err := base64.StdEncoding.DecodeString(a.Key); err != nil {
+			return Azure{}, fmt.Errorf("invalid Azure account key: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a Azure) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	var segments []string
+	for _, s := range strings.Split(key, "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+
+	u := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(a.Endpoint, "/"), url.PathEscape(a.Container), strings.Join(segments, "/"))
+	if a.SASToken != "" {
+		u += "?" + a.SASToken
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureVersion)
+
+	if a.Key != "" && a.SASToken == "" {
+		err = a.sign(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound && res.Header.Get("x-ms-error-code") != "ContainerNotFound" {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, responseError(res)
+	}
+
+	return res.Body, nil
+}
+
+func (a Azure) URL(key string) string {
+	return fmt.Sprintf("azblob://%s/%s/%s", a.Account, a.Container, key)
+}
+
+// sign adds the Shared Key Authorization header to a request.
+// See https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key.
+func (a Azure) sign(req *http.Request) error {
+	key, err := base64.StdEncoding.DecodeString(a.Key)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(a.stringToSign(req)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", a.Account, signature))
+	return nil
+}
+
+// stringToSign returns the string which is signed for Shared Key authorization.
+func (a Azure) stringToSign(req *http.Request) string {
+	// the standard headers which are signed, in order. Content-Length
+	// is empty rather than zero, and the Date is empty as x-ms-date is set.
+	lines := []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		"", // Content-Length
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}
+	if req.ContentLength > 0 {
+		lines[3] = fmt.Sprint(req.ContentLength)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ms-") {
+			msHeaders = append(msHeaders, lower+":"+strings.TrimSpace(req.Header.Get(name)))
+		}
+	}
+	sort.Strings(msHeaders)
+
+	resource := "/" + a.Account + req.URL.EscapedPath()
+
+	query := req.URL.Query()
+	var params []string
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	return strings.Join(lines, "\n") + "\n" + strings.Join(msHeaders, "\n") + "\n" + resource
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// azuriteKey is the well-known account key of the Azurite emulator.
+const azuriteKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func TestAzure_Get(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// recompute the signature from the documented string to sign
+		stringToSign := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+			"x-ms-date:" + r.Header.Get("x-ms-date") + "\n" +
+			"x-ms-version:" + azureVersion + "\n" +
+			"/devstoreaccount1" + r.URL.EscapedPath()
+
+		key, _ := base64.StdEncoding.DecodeString(azuriteKey)
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(stringToSign))
+		want := "SharedKey devstoreaccount1:" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		if r.Header.Get("Authorization") != want {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.URL.Path != "/devstoreaccount1/packages/dists/stable/Release" {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "Suite: stable\n")
+	}))
+	defer srv.Close()
+
+	a, err := NewAzure(AzureOptions{
+		Account:   "devstoreaccount1",
+		Container: "packages",
+		Endpoint:  srv.URL + "/devstoreaccount1",
+		Key:       azuriteKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Client = srv.Client()
+
+	testGet(t, a)
+
+	if got := a.URL("dists/stable/Release"); got != "azblob://devstoreaccount1/packages/dists/stable/Release" {
+		t.Errorf("unexpected URL %q", got)
+	}
+}
+
+func TestAzure_Get_ContainerNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-error-code", "ContainerNotFound")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	a, err := NewAzure(AzureOptions{Account: "example", Container: "packages", Endpoint: srv.URL, SASToken: "?sv=2021&sig=secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing container is a configuration error, rather than a missing object
+	_, err = a.Get(context.Background(), "dists/stable/Release")
+	if err == nil || err == ErrNotFound {
+		t.Fatalf("expected an error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the SAS token to be omitted from the error, got %v", err)
+	}
+}
+
+// TestAzure_Emulator runs against Azurite:
+//
+//	docker run -d -p 10000:10000 mcr.microsoft.com/azure-storage/azurite azurite-blob --blobHost 0.0.0.0
+//	LINUXPACK_TEST_AZURE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1 go test ./pkg/storage
+//
+// It is skipped if LINUXPACK_TEST_AZURE_ENDPOINT is not set.
+func TestAzure_Emulator(t *testing.T) {
+	endpoint := os.Getenv("LINUXPACK_TEST_AZURE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("LINUXPACK_TEST_AZURE_ENDPOINT is not set")
+	}
+
+	a, err := NewAzure(AzureOptions{
+		Account:   "devstoreaccount1",
+		Container: "linuxpack-test",
+		Endpoint:  endpoint,
+		Key:       azuriteKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(u string, body string, headers map[string]string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPut, u, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+		req.Header.Set("x-ms-version", azureVersion)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if err := a.sign(req); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		// the container may exist from a previous run
+		if res.StatusCode >= 300 && res.StatusCode != http.StatusConflict {
+			t.Fatalf("unexpected response %s from %s", res.Status, u)
+		}
+	}
+
+	put(endpoint+"/linuxpack-test?restype=container", "", nil)
+	put(endpoint+"/linuxpack-test/dists/stable/Release", "Suite: stable\n", map[string]string{"x-ms-blob-type": "BlockBlob"})
+
+	testGet(t, a)
+}
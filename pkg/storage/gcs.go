@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultGCSEndpoint is the endpoint of the Google Cloud Storage JSON API.
+const DefaultGCSEndpoint = "https://storage.googleapis.com"
+
+// GCS stores a repository in a Google Cloud Storage bucket,
+// using the JSON API.
+type GCS struct {
+	Client *http.Client
+	Bucket string
+	// Endpoint is the URL of the JSON API, which can be set
+	// to use an emulator such as fake-gcs-server.
+	Endpoint string
+	// Token returns the OAuth 2.0 access token to authorize requests
+	// with. If nil, requests are unauthenticated, which emulators allow.
+	Token func(ctx context.Context) (string, error)
+}
+
+// GCSOptions configures the client for a GCS bucket.
+type GCSOptions struct {
+	Bucket string
+	// Endpoint overrides DefaultGCSEndpoint, such as to use an emulator.
+	Endpoint string
+}
+
+// NewGCS returns the storage for a GCS bucket. Requests are authorized with
+// the token in the GOOGLE_OAUTH_ACCESS_TOKEN environment variable, or else
+// with a token from the gcloud command line tool. Requests to a custom
+// endpoint without a token in the environment are unauthenticated, as
+// they are to an emulator.
+func NewGCS(ctx context.Context, opts GCSOptions) (GCS, error) {
+	g := GCS{
+		Client:   http.DefaultClient,
+		Bucket:   opts.Bucket,
+		Endpoint: opts.Endpoint,
+	}
+
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		g.Token = func(ctx context.Context) (string, error) { return token, nil }
+	} else if g.Endpoint == "" {
+		g.Token = gcloudToken
+	}
+
+	if g.Endpoint == "" {
+		g.Endpoint = DefaultGCSEndpoint
+	}
+
+	return g, nil
+}
+
+// gcloudToken returns an access token for the active gcloud account.
+func gcloudToken(ctx context.Context) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error getting a GCS access token from gcloud (set GOOGLE_OAUTH_ACCESS_TOKEN to provide one): %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g GCS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", g.endpoint(), url.PathEscape(g.Bucket), url.PathEscape(key))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := g.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		defer res.Body.Close()
+		return nil, notFoundError(res)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, responseError(res)
+	}
+
+	return res.Body, nil
+}
+
+func (g GCS) URL(key string) string {
+	return fmt.Sprintf("gs://%s/%s", g.Bucket, key)
+}
+
+// endpoint returns the endpoint without a trailing slash.
+func (g GCS) endpoint() string {
+	return strings.TrimSuffix(g.Endpoint, "/")
+}
+
+// do authorizes and sends a request.
+func (g GCS) do(req *http.Request) (*http.Response, error) {
+	if g.Token != nil {
+		token, err := g.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return g.Client.Do(req)
+}
+
+// notFoundError returns ErrNotFound for a 404 response for a missing
+// object. A missing bucket is a configuration error, rather than a
+// missing object, so an error describing the response is returned.
+func notFoundError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &e)
+
+	// GCS returns "The specified bucket does not exist.",
+	// and fake-gcs-server returns "bucket not found"
+	msg := strings.ToLower(e.Error.Message)
+	if strings.Contains(msg, "bucket does not exist") || strings.Contains(msg, "bucket not found") {
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return responseError(res)
+	}
+	return ErrNotFound
+}
+
+// responseError returns an error for an unexpected HTTP response,
+// including the start of the response body which describes the error.
+func responseError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+	// the query is omitted as it may contain a SAS token
+	u := *res.Request.URL
+	u.RawQuery = ""
+
+	return fmt.Errorf("unexpected response %s from %s: %s", res.Status, u.String(), strings.TrimSpace(string(body)))
+}
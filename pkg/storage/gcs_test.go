@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGCS_Get(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the object name is escaped as a single path segment
+		if r.URL.EscapedPath() != "/storage/v1/b/example-bucket/o/dists%2Fstable%2FRelease" || r.URL.Query().Get("alt") != "media" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error": {"code": 404, "message": "No such object: example-bucket/dists/nightly/Release"}}`)
+			return
+		}
+		io.WriteString(w, "Suite: stable\n")
+	}))
+	defer srv.Close()
+
+	g := GCS{
+		Client:   srv.Client(),
+		Bucket:   "example-bucket",
+		Endpoint: srv.URL,
+		Token:    func(ctx context.Context) (string, error) { return "token", nil },
+	}
+
+	testGet(t, g)
+
+	if got := g.URL("dists/stable/Release"); got != "gs://example-bucket/dists/stable/Release" {
+		t.Errorf("unexpected URL %q", got)
+	}
+}
+
+func TestGCS_Get_BucketNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error": {"code": 404, "message": "The specified bucket does not exist.", "errors": [{"message": "The specified bucket does not exist.", "domain": "global", "reason": "notFound"}]}}`)
+	}))
+	defer srv.Close()
+
+	g := GCS{Client: srv.Client(), Bucket: "missing-bucket", Endpoint: srv.URL}
+
+	// a missing bucket is a configuration error, rather than a missing object
+	_, err := g.Get(context.Background(), "dists/stable/Release")
+	if err == nil || err == ErrNotFound {
+		t.Fatalf("expected an error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "The specified bucket does not exist.") {
+		t.Errorf("expected the error to describe the missing bucket, got %v", err)
+	}
+}
+
+// TestGCS_Emulator runs against fake-gcs-server:
+//
+//	docker run -d -p 4443:4443 fsouza/fake-gcs-server -scheme http
+//	LINUXPACK_TEST_GCS_ENDPOINT=http://localhost:4443 go test ./pkg/storage
+//
+// It is skipped if LINUXPACK_TEST_GCS_ENDPOINT is not set.
+func TestGCS_Emulator(t *testing.T) {
+	endpoint := os.Getenv("LINUXPACK_TEST_GCS_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("LINUXPACK_TEST_GCS_ENDPOINT is not set")
+	}
+
+	ctx := context.Background()
+
+	g, err := NewGCS(ctx, GCSOptions{Bucket: "linuxpack-test", Endpoint: endpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bucket may exist from a previous run
+	res, err := http.Post(endpoint+"/storage/v1/b", "application/json", strings.NewReader(`{"name": "linuxpack-test"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	res, err = http.Post(endpoint+"/upload/storage/v1/b/linuxpack-test/o?uploadType=media&name=dists/stable/Release", "text/plain", strings.NewReader("Suite: stable\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("error uploading test object: %s", res.Status)
+	}
+
+	testGet(t, g)
+}
+
+// testGet checks that a storage holds dists/stable/Release,
+// and returns ErrNotFound for dists/nightly/Release.
+func testGet(t *testing.T, store Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	body, err := store.Get(ctx, "dists/stable/Release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Suite: stable\n" {
+		t.Errorf("unexpected object contents %q", data)
+	}
+
+	_, err = store.Get(ctx, "dists/nightly/Release")
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local stores a repository in a directory on the local filesystem,
+// such as the output folder of a previous run.
+type Local struct {
+	Dir string
+}
+
+func (l Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (l Local) URL(key string) string {
+	return l.path(key)
+}
+
+// path returns the path of the file holding an object.
+func (l Local) path(key string) string {
+	return filepath.Join(l.Dir, filepath.FromSlash(key))
+}
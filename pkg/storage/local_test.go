@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(dir, "dists", "stable"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "dists", "stable", "Release"), []byte("Suite: stable\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := Local{Dir: dir}
+
+	testGet(t, l)
+}
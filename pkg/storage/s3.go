@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 stores a repository in an S3 bucket.
+type S3 struct {
+	Client *s3.Client
+	Bucket string
+}
+
+// S3Options configures the client for an S3 bucket.
+type S3Options struct {
+	Bucket string
+	// Region overrides the region from the AWS config.
+	Region string
+	// Endpoint is the URL of an S3-compatible service, such as
+	// MinIO, Cloudflare R2 or Ceph. If empty, AWS S3 is used.
+	Endpoint string
+	// UsePathStyle addresses the bucket in the URL path rather than
+	// the host name, which most S3-compatible services require.
+	UsePathStyle bool
+	// Profile is the shared config profile to load credentials from.
+	// If empty, the default credentials chain is used.
+	Profile string
+}
+
+// NewS3 returns the storage for an S3 bucket, loading
+// credentials from the environment and shared AWS config.
+func NewS3(ctx context.Context, opts S3Options) (S3, error) {
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(opts.Region))
+	}
+	if opts.Profile != "" {
+		loadOpts = append(loadOpts, awsconfig.WithSharedConfigProfile(opts.Profile))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return S3{}, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+		o.UsePathStyle = opts.UsePathStyle
+	})
+
+	return S3{Client: client, Bucket: opts.Bucket}, nil
+}
+
+func (s S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.Bucket,
+		Key:    &key,
+	})
+	var nsk *types.NoSuchKey
+	if errors.As(err, &nsk) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s S3) URL(key string) string {
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, key)
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestS3 runs against an S3-compatible service, such as a MinIO container:
+//
+//	docker run -d -p 9000:9000 -e MINIO_ROOT_USER=minioadmin -e MINIO_ROOT_PASSWORD=minioadmin minio/minio server /data
+//	AWS_ACCESS_KEY_ID=minioadmin AWS_SECRET_ACCESS_KEY=minioadmin AWS_REGION=us-east-1 \
+//	  LINUXPACK_TEST_S3_ENDPOINT=http://localhost:9000 go test ./pkg/storage
+//
+// It is skipped if LINUXPACK_TEST_S3_ENDPOINT is not set.
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("LINUXPACK_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("LINUXPACK_TEST_S3_ENDPOINT is not set")
+	}
+
+	ctx := context.Background()
+
+	store, err := NewS3(ctx, S3Options{
+		Bucket:       "linuxpack-test",
+		Endpoint:     endpoint,
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bucket may exist from a previous run
+	_, _ = store.Client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(store.Bucket)})
+
+	_, err = store.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    aws.String("dists/stable/Release"),
+		Body:   strings.NewReader("Suite: stable\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := store.Get(ctx, "dists/stable/Release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Suite: stable\n" {
+		t.Errorf("unexpected object contents %q", data)
+	}
+
+	_, err = store.Get(ctx, "dists/nightly/Release")
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewS3_Endpoint(t *testing.T) {
+	// the credentials are read from the environment rather than the user's config
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", dir+"/config")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", dir+"/credentials")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		if r.URL.Path != "/example-bucket/dists/stable/Release" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		io.WriteString(w, "Suite: stable\n")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	store, err := NewS3(ctx, S3Options{
+		Bucket:       "example-bucket",
+		Region:       "us-east-1",
+		Endpoint:     srv.URL,
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := store.Get(ctx, "dists/stable/Release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Suite: stable\n" {
+		t.Errorf("unexpected object contents %q", data)
+	}
+
+	_, err = store.Get(ctx, "dists/nightly/Release")
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// path-style requests include the bucket in the path
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"/example-bucket/dists/stable/Release", "/example-bucket/dists/nightly/Release"}
+	if diff := cmp.Diff(want, paths); diff != "" {
+		t.Errorf("request paths mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Storage is a backend which holds a published repository,
+// such as an S3 bucket.
+type Storage interface {
+	// Get reads an object. If the object does not exist,
+	// ErrNotFound is returned.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// URL returns a human-readable location of an object,
+	// used in log messages.
+	URL(key string) string
+}
@@ -0,0 +1,149 @@
+package translation
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Translation is a Translation-<lang> index, holding the long descriptions
+// of packages. Descriptions are referenced from the Packages index by
+// their Description-md5 field.
+type Translation struct {
+	// Lang is the language code of the descriptions, such as "en".
+	Lang         string
+	Descriptions map[Key]string
+}
+
+// Key identifies a description in a Translation index.
+type Key struct {
+	Package string
+	MD5     string
+}
+
+// DescriptionMD5 returns the Description-md5 value for a description.
+// As with dpkg, the checksum covers the full description, including
+// the long description and a trailing newline.
+func DescriptionMD5(description string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(description+"\n")))
+}
+
+// Synopsis returns the first line of a description.
+func Synopsis(description string) string {
+	synopsis, _, _ := strings.Cut(description, "\n")
+	return synopsis
+}
+
+// Add records the description of a package.
+func (t *Translation) Add(pkg string, description string) Key {
+	if t.Descriptions == nil {
+		t.Descriptions = map[Key]string{}
+	}
+
+	key := Key{Package: pkg, MD5: DescriptionMD5(description)}
+	t.Descriptions[key] = description
+	return key
+}
+
+// Write writes the index sorted by package and checksum.
+func (t *Translation) Write(w io.Writer) error {
+	var keys []Key
+	for k := range t.Descriptions {
+		keys = append(keys, k)
+	}
+
+	slices.SortFunc(keys, func(a, b Key) int {
+		if c := strings.Compare(a.Package, b.Package); c != 0 {
+			return c
+		}
+		return strings.Compare(a.MD5, b.MD5)
+	})
+
+	for _, k := range keys {
+		_, err := fmt.Fprintf(w, "Package: %s\nDescription-md5: %s\nDescription-%s: %s\n\n", k.Package, k.MD5, t.lang(), t.Descriptions[k])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Translation) lang() string {
+	if t.Lang == "" {
+		return "en"
+	}
+	return t.Lang
+}
+
+// Read reads a Translation index.
+func Read(r io.Reader, lang string) (Translation, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	t := Translation{Lang: lang}
+	descriptionKey := "Description-" + t.lang()
+
+	var key Key
+	var description string
+	var lastKey string
+
+	flush := func() {
+		if key.Package != "" && description != "" {
+			if key.MD5 == "" {
+				key.MD5 = DescriptionMD5(description)
+			}
+			if t.Descriptions == nil {
+				t.Descriptions = map[Key]string{}
+			}
+			t.Descriptions[key] = description
+		}
+		key = Key{}
+		description = ""
+		lastKey = ""
+	}
+
+	var lineNum int
+	for sc.Scan() {
+		lineNum++
+		line := sc.Text()
+
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if lastKey == descriptionKey {
+				description += "\n" + line
+			}
+			continue
+		}
+
+		before, after, found := strings.Cut(line, ": ")
+		if !found {
+			return Translation{}, fmt.Errorf("invalid line %v: did not contain a \": \" separator: %q", lineNum, line)
+		}
+		lastKey = before
+
+		switch before {
+		case "Package":
+			key.Package = after
+		case "Description-md5":
+			key.MD5 = after
+		case descriptionKey:
+			description = after
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return Translation{}, err
+	}
+
+	flush()
+
+	return t, nil
+}
@@ -0,0 +1,38 @@
+package translation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var want Translation
+	want.Lang = "en"
+	want.Add("granted", "The easiest way to access your cloud.\n Granted is a command line interface tool.\n .\n It supports multiple profiles.")
+	want.Add("assume", "Assume cloud roles.")
+
+	var buf bytes.Buffer
+	if err := want.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(&buf, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDescriptionMD5(t *testing.T) {
+	// matches `printf 'Assume cloud roles.\n' | md5sum`
+	got := DescriptionMD5("Assume cloud roles.")
+	want := "b1311d735f7832805b58ec847bc1073a"
+	if got != want {
+		t.Errorf("DescriptionMD5() = %s, want %s", got, want)
+	}
+}